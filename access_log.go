@@ -0,0 +1,145 @@
+package rapidus
+
+import (
+	"context"
+	"fmt"
+	"github.com/fouched/rapidus/ratelimit"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogConfig configures the access log. Requests are sampled at SampleRate (0 to 1),
+// but server errors are always logged
+type AccessLogConfig struct {
+	Enabled      bool
+	SampleRate   float64
+	SkipPaths    []string
+	RedactParams []string
+	Logger       *slog.Logger
+}
+
+type accessLogKey struct{}
+
+// accessLogEntry collects values that are only known further down the middleware chain
+type accessLogEntry struct {
+	userID string
+}
+
+func (r *Rapidus) accessLogConfigFromEnv() AccessLogConfig {
+	cfg := AccessLogConfig{
+		SampleRate:   1,
+		SkipPaths:    envList("ACCESS_LOG_SKIP_PATHS"),
+		RedactParams: []string{"token", "hash", "password", "signature", "key"},
+	}
+
+	cfg.Enabled, _ = strconv.ParseBool(os.Getenv("ACCESS_LOG"))
+
+	if rate, err := strconv.ParseFloat(os.Getenv("ACCESS_LOG_SAMPLE_RATE"), 64); err == nil {
+		cfg.SampleRate = rate
+	}
+
+	if redact := envList("ACCESS_LOG_REDACT"); len(redact) > 0 {
+		cfg.RedactParams = redact
+	}
+
+	if r.Debug {
+		cfg.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	} else {
+		cfg.Logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+
+	return cfg
+}
+
+// AccessLog logs every request with its route pattern, status, size and duration
+func (r *Rapidus) AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cfg := r.AccessLogs
+		if !cfg.Enabled || r.skipAccessLog(req.URL.Path) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		start := time.Now()
+		entry := &accessLogEntry{}
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			if status < 500 && cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+				return
+			}
+
+			route := req.URL.Path
+			if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			cfg.Logger.LogAttrs(req.Context(), slog.LevelInfo, "request",
+				slog.String("method", req.Method),
+				slog.String("route", route),
+				slog.String("url", redactURL(req.URL, cfg.RedactParams)),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_ip", ratelimit.KeyByIP(req)),
+				slog.String("request_id", middleware.GetReqID(req.Context())),
+				slog.String("user_id", entry.userID),
+			)
+		}()
+
+		next.ServeHTTP(ww, req.WithContext(context.WithValue(req.Context(), accessLogKey{}, entry)))
+	})
+}
+
+// logSessionUser records the logged in user for the access log, once the session is loaded
+func (r *Rapidus) logSessionUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if entry, ok := req.Context().Value(accessLogKey{}).(*accessLogEntry); ok {
+			if userID := r.Session.Get(req.Context(), "userID"); userID != nil {
+				entry.userID = fmt.Sprint(userID)
+			}
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *Rapidus) skipAccessLog(path string) bool {
+	for _, skip := range r.AccessLogs.SkipPaths {
+		if path == skip || (strings.HasSuffix(skip, "*") && strings.HasPrefix(path, strings.TrimSuffix(skip, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL replaces the values of sensitive query parameters
+func redactURL(u *url.URL, params []string) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	query := u.Query()
+	for key := range query {
+		for _, p := range params {
+			if strings.EqualFold(key, p) {
+				query.Set(key, "REDACTED")
+			}
+		}
+	}
+
+	return u.Path + "?" + query.Encode()
+}
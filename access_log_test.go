@@ -0,0 +1,185 @@
+package rapidus
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// accessLogHandler returns a handler that logs requests as JSON lines to buf, and responds with
+// the status in the status query parameter
+func accessLogHandler(cfg AccessLogConfig, buf *bytes.Buffer) http.Handler {
+	app := newTestApp()
+	cfg.Enabled = true
+	cfg.Logger = slog.New(slog.NewJSONHandler(buf, nil))
+	app.AccessLogs = cfg
+
+	return app.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Query().Get("status") {
+		case "500":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "503":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "404":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRapidus_AccessLog_sampling(t *testing.T) {
+	tests := []struct {
+		name       string
+		sampleRate float64
+		target     string
+		logged     bool
+	}{
+		{"all sampled", 1, "/", true},
+		{"none sampled", 0, "/", false},
+		{"client error not sampled", 0, "/?status=404", false},
+		{"server error always logged", 0, "/?status=500", true},
+		{"unavailable always logged", 0, "/?status=503", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := accessLogHandler(AccessLogConfig{SampleRate: tt.sampleRate}, &buf)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.target, nil))
+
+			if logged := buf.Len() > 0; logged != tt.logged {
+				t.Errorf("expected logged to be %v, got %q", tt.logged, buf.String())
+			}
+		})
+	}
+}
+
+func TestRapidus_AccessLog_sampleRate(t *testing.T) {
+	var buf bytes.Buffer
+	handler := accessLogHandler(AccessLogConfig{SampleRate: 0.5}, &buf)
+
+	const requests = 1000
+	for i := 0; i < requests; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	// the chance of falling outside these bounds is far below one in a billion
+	if n := len(logLines(t, &buf)); n < 350 || n > 650 {
+		t.Errorf("expected about half of %d requests to be logged, got %d", requests, n)
+	}
+}
+
+func TestRapidus_AccessLog_skipPaths(t *testing.T) {
+	tests := []struct {
+		path   string
+		logged bool
+	}{
+		{"/health", false},
+		{"/healthz", true},
+		{"/health/db", true},
+		{"/static/", false},
+		{"/static/css/app.css", false},
+		{"/staticfile", true},
+		{"/metrics", false},
+		{"/metricsx", false},
+		{"/", true},
+	}
+
+	var buf bytes.Buffer
+	handler := accessLogHandler(AccessLogConfig{SampleRate: 1, SkipPaths: []string{"/health", "/static/*", "/metrics*"}}, &buf)
+	for _, tt := range tests {
+		buf.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+
+		if logged := buf.Len() > 0; logged != tt.logged {
+			t.Errorf("%s: expected logged to be %v, got %q", tt.path, tt.logged, buf.String())
+		}
+	}
+}
+
+func TestRapidus_AccessLog_redact(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   url.Values
+	}{
+		{"no query", "/users", nil},
+		{"nothing to redact", "/users?page=2", url.Values{"page": {"2"}}},
+		{
+			"signed url",
+			"/verify?email=a%40example.com&expires=1700000000&hash=abc123&signature=def456",
+			url.Values{"email": {"a@example.com"}, "expires": {"1700000000"}, "hash": {"REDACTED"}, "signature": {"REDACTED"}},
+		},
+		{"token", "/reset?token=secret-token&page=1", url.Values{"token": {"REDACTED"}, "page": {"1"}}},
+		{"case insensitive", "/reset?Token=secret-token", url.Values{"Token": {"REDACTED"}}},
+		{"repeated", "/reset?token=one&token=two", url.Values{"token": {"REDACTED"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := accessLogHandler(AccessLogConfig{SampleRate: 1, RedactParams: []string{"hash", "signature", "token"}}, &buf)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.target, nil))
+
+			for _, secret := range []string{"abc123", "def456", "secret-token", "one", "two"} {
+				if strings.Contains(buf.String(), secret) {
+					t.Errorf("expected %q to be redacted, got %s", secret, buf.String())
+				}
+			}
+
+			lines := logLines(t, &buf)
+			if len(lines) != 1 {
+				t.Fatalf("expected one line, got %d", len(lines))
+			}
+
+			logged, err := url.Parse(lines[0]["url"].(string))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := logged.Query()
+			if len(got) == 0 {
+				got = nil
+			}
+			if logged.Path != strings.SplitN(tt.target, "?", 2)[0] || got.Encode() != tt.want.Encode() {
+				t.Errorf("expected %s?%s, got %s", logged.Path, tt.want.Encode(), lines[0]["url"])
+			}
+		})
+	}
+}
+
+func TestRapidus_AccessLog_fields(t *testing.T) {
+	var buf bytes.Buffer
+	handler := accessLogHandler(AccessLogConfig{SampleRate: 1}, &buf)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/orders?status=404", nil))
+
+	lines := logLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("expected one line, got %d", len(lines))
+	}
+
+	entry := lines[0]
+	if entry["msg"] != "request" || entry["method"] != "POST" || entry["route"] != "/orders" || entry["status"] != float64(404) {
+		t.Errorf("unexpected entry %v", entry)
+	}
+}
@@ -0,0 +1,227 @@
+package rapidus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/a-h/templ"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxJSONBytes is the largest request body BindJSON accepts
+var MaxJSONBytes int64 = 1 << 20
+
+// Envelope is the standard body of successful API responses
+type Envelope struct {
+	Data interface{} `json:"data" xml:"data"`
+	Meta *Pagination `json:"meta,omitempty" xml:"meta,omitempty"`
+}
+
+// Pagination describes the page of a list returned in an Envelope
+type Pagination struct {
+	Page       int `json:"page" xml:"page"`
+	PerPage    int `json:"per_page" xml:"per_page"`
+	Total      int `json:"total" xml:"total"`
+	TotalPages int `json:"total_pages" xml:"total_pages"`
+}
+
+// NewPagination calculates the number of pages for total items
+func NewPagination(page, perPage, total int) *Pagination {
+	p := &Pagination{Page: page, PerPage: perPage, Total: total}
+	if perPage > 0 {
+		p.TotalPages = (total + perPage - 1) / perPage
+	}
+	return p
+}
+
+// WriteData writes data in an Envelope as JSON
+func (r *Rapidus) WriteData(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	return r.WriteJSON(w, status, Envelope{Data: data}, headers...)
+}
+
+// WritePage writes a page of a list, with its pagination, in an Envelope as JSON
+func (r *Rapidus) WritePage(w http.ResponseWriter, data interface{}, pagination *Pagination, headers ...http.Header) error {
+	return r.WriteJSON(w, http.StatusOK, Envelope{Data: data, Meta: pagination}, headers...)
+}
+
+// Respond writes data as JSON, XML or html, depending on what the client accepts. The page
+// is rendered for browsers; when it is nil, only JSON and XML are offered
+func (r *Rapidus) Respond(w http.ResponseWriter, req *http.Request, status int, data interface{}, page templ.Component) error {
+	offers := []string{"application/json", "application/xml"}
+	if page != nil {
+		offers = append(offers, "text/html")
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	switch negotiate(req, offers...) {
+	case "text/html":
+		w.WriteHeader(status)
+		return r.Render.Template(w, req, page)
+	case "application/xml":
+		return r.WriteXML(w, status, Envelope{Data: data})
+	default:
+		return r.WriteJSON(w, status, Envelope{Data: data})
+	}
+}
+
+// BindJSON decodes a JSON request body into dst. Malformed JSON, unknown fields, more than one
+// JSON value and bodies larger than MaxJSONBytes are rejected with a problem response, in which
+// case BindJSON returns false and the handler should return
+func (r *Rapidus) BindJSON(w http.ResponseWriter, req *http.Request, dst interface{}) bool {
+	err := decodeJSON(w, req, dst)
+	if err == nil {
+		return true
+	}
+
+	var jsonErr *jsonError
+	if errors.As(err, &jsonErr) {
+		r.ErrorProblem(w, req, jsonErr.status, jsonErr.detail)
+	} else {
+		r.ErrorProblem(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	return false
+}
+
+// jsonError is a request body that can't be decoded, with the status to respond with
+type jsonError struct {
+	status int
+	detail string
+}
+
+func (e *jsonError) Error() string {
+	return e.detail
+}
+
+func decodeJSON(w http.ResponseWriter, req *http.Request, dst interface{}) error {
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			return &jsonError{http.StatusUnsupportedMediaType, "Content-Type must be application/json"}
+		}
+	}
+
+	req.Body = http.MaxBytesReader(w, req.Body, MaxJSONBytes)
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var typeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return &jsonError{http.StatusBadRequest, fmt.Sprintf("body contains malformed JSON at position %d", syntaxError.Offset)}
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return &jsonError{http.StatusBadRequest, "body contains malformed JSON"}
+		case errors.As(err, &typeError):
+			if typeError.Field != "" {
+				return &jsonError{http.StatusBadRequest, fmt.Sprintf("body contains the wrong type for field %q", typeError.Field)}
+			}
+			return &jsonError{http.StatusBadRequest, fmt.Sprintf("body contains the wrong type at position %d", typeError.Offset)}
+		case errors.Is(err, io.EOF):
+			return &jsonError{http.StatusBadRequest, "body must not be empty"}
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return &jsonError{http.StatusBadRequest, fmt.Sprintf("body contains unknown field %s", field)}
+		case errors.As(err, &maxBytesError):
+			return &jsonError{http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxBytesError.Limit)}
+		default:
+			return err
+		}
+	}
+
+	if err = dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return &jsonError{http.StatusBadRequest, "body must only contain a single JSON value"}
+	}
+
+	return nil
+}
+
+// negotiate returns the offered media type the client accepts with the highest quality,
+// or the first offer when the client accepts anything
+func negotiate(req *http.Request, offers ...string) string {
+	accept := req.Header.Get("Accept")
+	if accept == "" || len(offers) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	type acceptRange struct {
+		mediaType string
+		q         float64
+	}
+
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType, q})
+	}
+
+	// more specific ranges win over wildcards with the same quality
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	// a type refused with q=0 is not acceptable, even when a wildcard would match it
+	refused := make(map[string]bool)
+	for _, r := range ranges {
+		if r.q <= 0 {
+			refused[r.mediaType] = true
+		}
+	}
+
+	for _, r := range ranges {
+		if r.q <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			if !refused[offer] && mediaTypeMatches(r.mediaType, offer) {
+				return offer
+			}
+		}
+	}
+
+	return offers[0]
+}
+
+func mediaTypeMatches(accepted, offer string) bool {
+	if accepted == "*/*" || accepted == offer {
+		return true
+	}
+
+	if prefix, ok := strings.CutSuffix(accepted, "/*"); ok {
+		return strings.HasPrefix(offer, prefix+"/")
+	}
+
+	// e.g. application/problem+json is acceptable to a client asking for application/json
+	if base, suffix, ok := strings.Cut(accepted, "+"); ok {
+		return offer == base[:strings.Index(base, "/")+1]+suffix
+	}
+
+	return false
+}
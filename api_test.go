@@ -0,0 +1,195 @@
+package rapidus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		offers []string
+		want   string
+	}{
+		{"no accept header", "", []string{"application/json", "text/html"}, "application/json"},
+		{"no offers", "application/json", nil, ""},
+		{"exact match", "application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+		{"anything", "*/*", []string{"text/html", "application/json"}, "text/html"},
+		{"quality", "application/json;q=0.5, application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+		{"specific over wildcard", "*/*, application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+		{"type wildcard", "text/*", []string{"application/json", "text/html"}, "text/html"},
+		{"suffix", "application/problem+json", []string{"text/html", "application/json"}, "application/json"},
+		{"refused", "application/json;q=0, */*;q=0.1", []string{"application/json", "application/xml"}, "application/xml"},
+		{"nothing acceptable", "image/png", []string{"application/json", "application/xml"}, "application/json"},
+		{"browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", []string{"application/json", "application/xml", "text/html"}, "text/html"},
+		{"malformed range", "not a media type, application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if got := negotiate(req, tt.offers...); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRapidus_BindJSON(t *testing.T) {
+	type input struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		detail      string
+	}{
+		{"valid", "application/json", `{"name":"Ann","age":30}`, 0, ""},
+		{"json suffix", "application/merge-patch+json; charset=utf-8", `{"name":"Ann"}`, 0, ""},
+		{"no content type", "", `{"name":"Ann"}`, 0, ""},
+		{"wrong content type", "text/plain", `{"name":"Ann"}`, http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"malformed", "application/json", `{"name":}`, http.StatusBadRequest, "body contains malformed JSON at position 9"},
+		{"truncated", "application/json", `{"name":"Ann"`, http.StatusBadRequest, "body contains malformed JSON"},
+		{"wrong type", "application/json", `{"age":"thirty"}`, http.StatusBadRequest, `body contains the wrong type for field "age"`},
+		{"wrong top level type", "application/json", `[1]`, http.StatusBadRequest, "body contains the wrong type at position 1"},
+		{"empty", "application/json", ``, http.StatusBadRequest, "body must not be empty"},
+		{"unknown field", "application/json", `{"name":"Ann","admin":true}`, http.StatusBadRequest, `body contains unknown field "admin"`},
+		{"two values", "application/json", `{"name":"Ann"}{"name":"Bob"}`, http.StatusBadRequest, "body must only contain a single JSON value"},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", 64) + `"}`, http.StatusRequestEntityTooLarge, "body must not be larger than 32 bytes"},
+	}
+
+	defer func(max int64) { MaxJSONBytes = max }(MaxJSONBytes)
+	MaxJSONBytes = 32
+
+	app := newTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			var dst input
+			ok := app.BindJSON(w, req, &dst)
+			if tt.status == 0 {
+				if !ok || dst.Name != "Ann" {
+					t.Errorf("expected the body to bind, got %v %+v: %s", ok, dst, w.Body.String())
+				}
+				return
+			}
+
+			if ok {
+				t.Fatal("expected the body to be rejected")
+			}
+
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("expected a problem response, got %q: %v", w.Body.String(), err)
+			}
+			if w.Code != tt.status || p.Status != tt.status || p.Detail != tt.detail || p.Instance != "/users" {
+				t.Errorf("expected %d %q, got %d %+v", tt.status, tt.detail, w.Code, p)
+			}
+		})
+	}
+}
+
+func TestNewPagination(t *testing.T) {
+	tests := []struct {
+		page, perPage, total, totalPages int
+	}{
+		{1, 10, 0, 0},
+		{1, 10, 1, 1},
+		{1, 10, 10, 1},
+		{2, 10, 11, 2},
+		{3, 25, 100, 4},
+		{1, 0, 5, 0},
+	}
+	for _, tt := range tests {
+		p := NewPagination(tt.page, tt.perPage, tt.total)
+		if p.Page != tt.page || p.PerPage != tt.perPage || p.Total != tt.total || p.TotalPages != tt.totalPages {
+			t.Errorf("NewPagination(%d, %d, %d): expected %d pages, got %+v", tt.page, tt.perPage, tt.total, tt.totalPages, p)
+		}
+	}
+}
+
+func TestRapidus_WriteData(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter) error
+		want  string
+	}{
+		{
+			"data",
+			func(w http.ResponseWriter) error {
+				return app.WriteData(w, http.StatusCreated, map[string]int{"id": 1})
+			},
+			`{"data":{"id":1}}`,
+		},
+		{
+			"page",
+			func(w http.ResponseWriter) error {
+				return app.WritePage(w, []string{"a", "b"}, NewPagination(2, 2, 5))
+			},
+			`{"data":["a","b"],"meta":{"page":2,"per_page":2,"total":5,"total_pages":3}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if err := tt.write(w); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRapidus_Respond(t *testing.T) {
+	app := newTestApp()
+
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json", `{"data":{"id":1}}`},
+		{"application/json", "application/json", `{"data":{"id":1}}`},
+		{"application/xml", "application/xml", `<Envelope><data><id>1</id></data></Envelope>`},
+		{"text/html", "application/json", `{"data":{"id":1}}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/users/1", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+
+		type user struct {
+			ID int `json:"id" xml:"id"`
+		}
+		if err := app.Respond(w, req, http.StatusOK, user{ID: 1}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%q: expected Vary: Accept, got %q", tt.accept, w.Header().Get("Vary"))
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), tt.contentType) || strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("%q: expected %s %s, got %s %s", tt.accept, tt.contentType, tt.body, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
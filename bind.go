@@ -0,0 +1,86 @@
+package rapidus
+
+import (
+	"context"
+	"errors"
+	"github.com/fouched/rapidus/binding"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxFormMemory is how much of a multipart form is kept in memory, the rest is stored in temporary files
+const maxFormMemory = 32 << 20
+
+// Bind decodes the request into dst, a pointer to a struct, and validates it using its validate
+// struct tags. JSON bodies are decoded as JSON; forms and query strings are decoded from their
+// values, converting them to the field types. Values that can't be converted and failed rules
+// are returned in the Validation, which holds the submitted form values so a templ form can be
+// rendered again, or can be written with ValidationProblem. An error is only returned when the
+// request itself can't be read, e.g. for malformed JSON
+func (r *Rapidus) Bind(w http.ResponseWriter, req *http.Request, dst interface{}) (*Validation, error) {
+	v := r.Validator(url.Values{})
+
+	if isJSON(req) {
+		err := decodeJSON(w, req, dst)
+		if err != nil {
+			return v, err
+		}
+	} else {
+		err := parseForm(req)
+		if err != nil {
+			return v, err
+		}
+		v.Data = req.Form
+
+		err = binding.Decode(req.Form, dst)
+		var errs binding.Errors
+		if errors.As(err, &errs) {
+			addErrors(v, errs)
+		} else if err != nil {
+			return v, err
+		}
+	}
+
+	addErrors(v, r.rules().Validate(req.Context(), dst))
+
+	return v, nil
+}
+
+// ValidateStruct validates a struct using its validate struct tags
+func (r *Rapidus) ValidateStruct(ctx context.Context, s interface{}) *Validation {
+	v := r.Validator(nil)
+	addErrors(v, r.rules().Validate(ctx, s))
+	return v
+}
+
+// rules returns the struct tag validator, creating one if needed
+func (r *Rapidus) rules() *binding.Validator {
+	if r.Rules == nil {
+		r.Rules = r.createRules()
+	}
+	return r.Rules
+}
+
+// addErrors adds errors to a Validation, keeping the first error of each field
+func addErrors(v *Validation, errs map[string]string) {
+	for field, message := range errs {
+		if _, exists := v.Errors[field]; !exists {
+			v.Errors[field] = message
+		}
+	}
+}
+
+func isJSON(req *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func parseForm(req *http.Request) error {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return req.ParseMultipartForm(maxFormMemory)
+	}
+	return req.ParseForm()
+}
@@ -0,0 +1,274 @@
+package binding
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors holds an error message per field, keyed by the field's path, e.g. address.city or items[0].qty
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return "invalid field(s): " + strings.Join(fields, ", ")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// timeLayouts are tried in order when decoding a time.Time, and cover the values of html date inputs
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+// Decode decodes form or query string values into dst, which must be a pointer to a struct.
+// Fields are named by their form tag, then their json tag, then the field name. Nested structs
+// are read from dotted keys (address.city), slices from repeated keys (tags=a&tags=b) and slices
+// of structs from indexed keys (items[0].qty). Values that can't be converted to the field's
+// type are returned as Errors; the remaining fields are still decoded
+func Decode(values url.Values, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binding: dst must be a pointer to a struct, got %T", dst)
+	}
+
+	errs := make(Errors)
+	decodeStruct(values, v.Elem(), "", errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func decodeStruct(values url.Values, v reflect.Value, prefix string, errs Errors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if isEmbedded(sf) {
+			decodeStruct(values, v.Field(i), prefix, errs)
+			continue
+		}
+
+		name := fieldName(sf)
+		if name == "" {
+			continue
+		}
+
+		decodeValue(values, v.Field(i), prefix+name, errs)
+	}
+}
+
+func decodeValue(values url.Values, v reflect.Value, key string, errs Errors) {
+	switch {
+	case isScalar(v.Type()):
+		if raw, ok := values[key]; ok && len(raw) > 0 {
+			if msg := setScalar(v, raw[0]); msg != "" {
+				errs[key] = msg
+			}
+		}
+
+	case v.Kind() == reflect.Pointer:
+		if !hasKey(values, key) {
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		decodeValue(values, v.Elem(), key, errs)
+
+	case v.Kind() == reflect.Struct:
+		decodeStruct(values, v, key+".", errs)
+
+	case v.Kind() == reflect.Slice && isScalar(v.Type().Elem()):
+		raw := values[key]
+		if len(raw) == 0 {
+			raw = values[key+"[]"]
+		}
+		if len(raw) == 0 {
+			return
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(raw), len(raw))
+		for i, s := range raw {
+			if msg := setScalar(slice.Index(i), s); msg != "" {
+				errs[fmt.Sprintf("%s[%d]", key, i)] = msg
+			}
+		}
+		v.Set(slice)
+
+	case v.Kind() == reflect.Slice:
+		n := indexedLength(values, key)
+		if n == 0 {
+			return
+		}
+
+		slice := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			decodeValue(values, slice.Index(i), fmt.Sprintf("%s[%d]", key, i), errs)
+		}
+		v.Set(slice)
+	}
+}
+
+// isScalar reports whether a type is decoded from a single value
+func isScalar(t reflect.Type) bool {
+	if t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// setScalar converts s to the type of v, returning an error message when it can't. Empty values
+// leave v at its zero value, so that the required rule reports them rather than a conversion error
+func setScalar(v reflect.Value, s string) string {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && v.Type() != timeType {
+		if err := u.UnmarshalText([]byte(s)); err != nil {
+			return "Invalid value"
+		}
+		return ""
+	}
+
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return ""
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+
+	if v.Type() == timeType {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return ""
+			}
+		}
+		return "Must be a valid date"
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "on", "yes":
+			v.SetBool(true)
+		case "off", "no":
+			v.SetBool(false)
+		default:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return "Must be true or false"
+			}
+			v.SetBool(b)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return "Must be a whole number"
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return "Must be a positive whole number"
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return "Must be a number"
+		}
+		v.SetFloat(n)
+	}
+
+	return ""
+}
+
+// hasKey reports whether values holds key itself, or any key nested below it
+func hasKey(values url.Values, key string) bool {
+	for k := range values {
+		if k == key || k == key+"[]" || strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// indexedLength returns the length of the slice described by indexed keys, i.e. one more
+// than the highest index found below key
+func indexedLength(values url.Values, key string) int {
+	n := 0
+	for k := range values {
+		rest, ok := strings.CutPrefix(k, key+"[")
+		if !ok {
+			continue
+		}
+
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			continue
+		}
+
+		i, err := strconv.Atoi(rest[:end])
+		if err != nil || i < 0 || i >= maxSliceIndex {
+			continue
+		}
+
+		if i+1 > n {
+			n = i + 1
+		}
+	}
+	return n
+}
+
+// maxSliceIndex guards against a single request allocating a huge slice
+const maxSliceIndex = 1000
+
+// isEmbedded reports whether a field is an untagged embedded struct, whose fields are
+// bound as if they were declared in the outer struct
+func isEmbedded(sf reflect.StructField) bool {
+	return sf.Anonymous && sf.IsExported() && sf.Type.Kind() == reflect.Struct && sf.Type != timeType &&
+		sf.Tag.Get("form") == "" && sf.Tag.Get("json") == ""
+}
+
+// fieldName returns the name a struct field is bound and reported by, or "" when it is skipped
+func fieldName(sf reflect.StructField) string {
+	if !sf.IsExported() {
+		return ""
+	}
+
+	for _, tag := range []string{"form", "json"} {
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
@@ -0,0 +1,130 @@
+package binding
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type address struct {
+	Street string `form:"street"`
+	City   string `form:"city"`
+}
+
+type item struct {
+	SKU string `form:"sku"`
+	Qty int    `form:"qty"`
+}
+
+type order struct {
+	Name      string    `form:"name"`
+	Age       int       `form:"age"`
+	Price     float64   `json:"price"`
+	Subscribe bool      `form:"subscribe"`
+	Tags      []string  `form:"tags"`
+	IDs       []uint    `form:"ids"`
+	Delivery  time.Time `form:"delivery"`
+	Address   address   `form:"address"`
+	Billing   *address  `form:"billing"`
+	Items     []item    `form:"items"`
+	Ignored   string    `form:"-"`
+	Untagged  string
+	secret    string
+}
+
+func TestDecode(t *testing.T) {
+	values := url.Values{
+		"name":           {"Jack"},
+		"age":            {"42"},
+		"price":          {"9.95"},
+		"subscribe":      {"on"},
+		"tags":           {"a", "b"},
+		"ids[]":          {"1", "2", "3"},
+		"delivery":       {"2024-05-01"},
+		"address.street": {"1 Main Rd"},
+		"address.city":   {"Cape Town"},
+		"items[1].sku":   {"B"},
+		"items[0].sku":   {"A"},
+		"items[0].qty":   {"2"},
+		"Ignored":        {"x"},
+		"Untagged":       {"y"},
+		"secret":         {"z"},
+	}
+
+	var o order
+	err := Decode(values, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := order{
+		Name:      "Jack",
+		Age:       42,
+		Price:     9.95,
+		Subscribe: true,
+		Tags:      []string{"a", "b"},
+		IDs:       []uint{1, 2, 3},
+		Delivery:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Address:   address{Street: "1 Main Rd", City: "Cape Town"},
+		Items:     []item{{SKU: "A", Qty: 2}, {SKU: "B"}},
+		Untagged:  "y",
+	}
+
+	if !reflect.DeepEqual(o, expected) {
+		t.Errorf("expected %+v but got %+v", expected, o)
+	}
+
+	if o.Billing != nil {
+		t.Error("billing should not be allocated without values")
+	}
+}
+
+func TestDecode_Pointer(t *testing.T) {
+	var o order
+	_ = Decode(url.Values{"billing.city": {"Durban"}}, &o)
+
+	if o.Billing == nil || o.Billing.City != "Durban" {
+		t.Errorf("billing not decoded: %+v", o.Billing)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	values := url.Values{
+		"name":         {"Jack"},
+		"age":          {"forty"},
+		"ids":          {"1", "-2"},
+		"delivery":     {"tomorrow"},
+		"items[0].qty": {"many"},
+		"price":        {""},
+	}
+
+	var o order
+	err := Decode(values, &o)
+
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors but got %v", err)
+	}
+
+	for _, field := range []string{"age", "ids[1]", "delivery", "items[0].qty"} {
+		if _, ok := errs[field]; !ok {
+			t.Errorf("no error for %s in %v", field, errs)
+		}
+	}
+
+	if len(errs) != 4 {
+		t.Errorf("expected 4 errors but got %v", errs)
+	}
+
+	if o.Name != "Jack" {
+		t.Error("valid fields should still be decoded")
+	}
+}
+
+func TestDecode_NotAStruct(t *testing.T) {
+	var s string
+	if err := Decode(url.Values{}, &s); err == nil {
+		t.Error("no error decoding into a string")
+	}
+}
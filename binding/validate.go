@@ -0,0 +1,284 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Field is the struct field a rule is applied to
+type Field struct {
+	// Name is the path errors are reported under, e.g. address.city
+	Name string
+	// Value is the field's value, with pointers dereferenced
+	Value reflect.Value
+	// Parent is the struct that declares the field, for rules that compare fields
+	Parent reflect.Value
+	// Param is the part of the rule after the colon, e.g. 3 for min:3
+	Param string
+}
+
+// String returns the field's value formatted as a string
+func (f Field) String() string {
+	if !f.Value.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.Value.Interface())
+}
+
+// Rule checks a field, returning an error message, or "" when the field is valid
+type Rule func(ctx context.Context, f Field) string
+
+// Validator validates structs using their validate tags. Rules are separated by |, and take
+// a parameter after a colon, e.g. `validate:"required|min:3|max:50"`. Rules other than required
+// and eqfield are skipped for empty fields, so optional fields are only checked when given.
+// The regex rule takes the rest of the tag as its pattern, so it must be the last rule
+type Validator struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+// New creates a Validator with the built-in rules: required, email, min, max, oneof, regex and eqfield
+func New() *Validator {
+	return &Validator{
+		rules: map[string]Rule{
+			"required": required,
+			"email":    email,
+			"min":      minimum,
+			"max":      maximum,
+			"oneof":    oneOf,
+			"regex":    matches,
+			"eqfield":  equalField,
+		},
+	}
+}
+
+// runOnEmpty are the rules that are applied to fields with a zero value
+var runOnEmpty = map[string]bool{"required": true, "eqfield": true}
+
+// Register adds a rule, or replaces an existing one, under name
+func (v *Validator) Register(name string, rule Rule) {
+	v.mu.Lock()
+	v.rules[name] = rule
+	v.mu.Unlock()
+}
+
+// Validate validates s, a struct or a pointer to one, including its nested structs and slices
+// of structs. The returned Errors hold the first failing rule of each field and are empty when
+// s is valid. Validate panics when a tag uses a rule that is not registered
+func (v *Validator) Validate(ctx context.Context, s interface{}) Errors {
+	errs := make(Errors)
+
+	rv := reflect.Indirect(reflect.ValueOf(s))
+	if rv.Kind() == reflect.Struct {
+		v.validateStruct(ctx, rv, "", errs)
+	}
+
+	return errs
+}
+
+func (v *Validator) validateStruct(ctx context.Context, s reflect.Value, prefix string, errs Errors) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if isEmbedded(sf) {
+			v.validateStruct(ctx, s.Field(i), prefix, errs)
+			continue
+		}
+
+		name := fieldName(sf)
+		if name == "" {
+			continue
+		}
+
+		field := Field{
+			Name:   prefix + name,
+			Value:  reflect.Indirect(s.Field(i)),
+			Parent: s,
+		}
+
+		if msg := v.applyRules(ctx, field, sf.Tag.Get("validate")); msg != "" {
+			errs[field.Name] = msg
+			continue
+		}
+
+		v.validateNested(ctx, field.Value, field.Name, errs)
+	}
+}
+
+// validateNested validates the fields of nested structs and slices of structs
+func (v *Validator) validateNested(ctx context.Context, value reflect.Value, name string, errs Errors) {
+	if !value.IsValid() {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() != timeType {
+			v.validateStruct(ctx, value, name+".", errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			v.validateNested(ctx, reflect.Indirect(value.Index(i)), fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+	}
+}
+
+// applyRules applies the rules in a validate tag to a field, returning the first error message
+func (v *Validator) applyRules(ctx context.Context, field Field, tag string) string {
+	if tag == "" {
+		return ""
+	}
+
+	empty := !field.Value.IsValid() || field.Value.IsZero()
+	for tag != "" {
+		var rule string
+		rule, tag, _ = strings.Cut(tag, "|")
+
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), ":")
+		if name == "regex" && tag != "" {
+			// the pattern may contain |, so it runs to the end of the tag
+			param, tag = param+"|"+tag, ""
+		}
+
+		v.mu.RLock()
+		fn, ok := v.rules[name]
+		v.mu.RUnlock()
+		if !ok {
+			panic(fmt.Sprintf("binding: unknown validation rule %q on field %s", name, field.Name))
+		}
+
+		if empty && !runOnEmpty[name] {
+			continue
+		}
+
+		field.Param = param
+		if msg := fn(ctx, field); msg != "" {
+			return msg
+		}
+	}
+
+	return ""
+}
+
+func required(_ context.Context, f Field) string {
+	if !f.Value.IsValid() || f.Value.IsZero() ||
+		(f.Value.Kind() == reflect.String && strings.TrimSpace(f.Value.String()) == "") ||
+		((f.Value.Kind() == reflect.Slice || f.Value.Kind() == reflect.Map) && f.Value.Len() == 0) {
+		return "This field is required"
+	}
+	return ""
+}
+
+func email(_ context.Context, f Field) string {
+	address, err := mail.ParseAddress(f.String())
+	if err != nil || address.Address != f.String() {
+		return "Invalid email address"
+	}
+	return ""
+}
+
+func minimum(_ context.Context, f Field) string {
+	n, unit, ok := measure(f)
+	if ok && n < mustParseFloat(f) {
+		return "Must be at least " + f.Param + unit
+	}
+	return ""
+}
+
+func maximum(_ context.Context, f Field) string {
+	n, unit, ok := measure(f)
+	if ok && n > mustParseFloat(f) {
+		return "Must not be more than " + f.Param + unit
+	}
+	return ""
+}
+
+// measure returns what min and max compare: the length of strings and slices or the value of
+// numbers, with the unit used in error messages
+func measure(f Field) (float64, string, bool) {
+	switch f.Value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(f.Value.String())), " characters long", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(f.Value.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(f.Value.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(f.Value.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return f.Value.Float(), "", true
+	}
+	return 0, "", false
+}
+
+func mustParseFloat(f Field) float64 {
+	n, err := strconv.ParseFloat(f.Param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("binding: invalid parameter %q for field %s", f.Param, f.Name))
+	}
+	return n
+}
+
+func oneOf(_ context.Context, f Field) string {
+	options := strings.Fields(f.Param)
+	for _, option := range options {
+		if f.String() == option {
+			return ""
+		}
+	}
+	return "Must be one of: " + strings.Join(options, ", ")
+}
+
+var patterns sync.Map
+
+func matches(_ context.Context, f Field) string {
+	re, ok := patterns.Load(f.Param)
+	if !ok {
+		re, _ = patterns.LoadOrStore(f.Param, regexp.MustCompile(f.Param))
+	}
+
+	if !re.(*regexp.Regexp).MatchString(f.String()) {
+		return "Invalid format"
+	}
+	return ""
+}
+
+// equalField requires the field to equal another field of the same struct, named by its Go
+// name or by the name it is bound by, e.g. eqfield:Password
+func equalField(_ context.Context, f Field) string {
+	other, name := lookupField(f.Parent, f.Param)
+	if !other.IsValid() {
+		panic(fmt.Sprintf("binding: eqfield refers to unknown field %q on field %s", f.Param, f.Name))
+	}
+
+	other = reflect.Indirect(other)
+	if !f.Value.IsValid() || !other.IsValid() {
+		if f.Value.IsValid() == other.IsValid() {
+			return ""
+		}
+	} else if reflect.DeepEqual(f.Value.Interface(), other.Interface()) {
+		return ""
+	}
+
+	return "Must match " + name
+}
+
+// lookupField finds a field of s by its Go name or bound name, returning it with its bound name
+func lookupField(s reflect.Value, name string) (reflect.Value, string) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		bound := fieldName(sf)
+		if bound != "" && (sf.Name == name || bound == name) {
+			return s.Field(i), bound
+		}
+	}
+	return reflect.Value{}, ""
+}
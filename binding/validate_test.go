@@ -0,0 +1,113 @@
+package binding
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type registration struct {
+	Email    string     `json:"email" validate:"required|email"`
+	Name     string     `json:"name" validate:"required|min:2|max:10"`
+	Age      int        `json:"age" validate:"min:18"`
+	Plan     string     `json:"plan" validate:"oneof:free pro"`
+	Username string     `json:"username" validate:"regex:^[a-z]+$|^admin[0-9]$"`
+	Password string     `json:"password" validate:"required|min:8"`
+	Confirm  string     `json:"confirm" validate:"eqfield:Password"`
+	Tags     []string   `json:"tags" validate:"max:2"`
+	Address  *address   `json:"address"`
+	Items    []lineItem `json:"items" validate:"required"`
+}
+
+type lineItem struct {
+	SKU string `json:"sku" validate:"required"`
+	Qty int    `json:"qty" validate:"required|min:1"`
+}
+
+func valid() registration {
+	return registration{
+		Email:    "jack@example.com",
+		Name:     "Jack",
+		Age:      21,
+		Plan:     "pro",
+		Username: "admin1",
+		Password: "password",
+		Confirm:  "password",
+		Items:    []lineItem{{SKU: "A", Qty: 1}},
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		change func(r *registration)
+		field  string
+	}{
+		{"valid", func(r *registration) {}, ""},
+		{"required", func(r *registration) { r.Email = "  " }, "email"},
+		{"email", func(r *registration) { r.Email = "Jack <jack@example.com>" }, "email"},
+		{"min string", func(r *registration) { r.Name = "J" }, "name"},
+		{"max string", func(r *registration) { r.Name = "Jack Jackson" }, "name"},
+		{"min number", func(r *registration) { r.Age = 17 }, "age"},
+		{"optional", func(r *registration) { r.Age = 0; r.Plan = "" }, ""},
+		{"oneof", func(r *registration) { r.Plan = "gold" }, "plan"},
+		{"regex", func(r *registration) { r.Username = "Jack" }, "username"},
+		{"regex alternative", func(r *registration) { r.Username = "jack" }, ""},
+		{"eqfield", func(r *registration) { r.Confirm = "passw0rd" }, "confirm"},
+		{"eqfield empty", func(r *registration) { r.Confirm = "" }, "confirm"},
+		{"max slice", func(r *registration) { r.Tags = []string{"a", "b", "c"} }, "tags"},
+		{"required slice", func(r *registration) { r.Items = nil }, "items"},
+		{"nested slice", func(r *registration) { r.Items = append(r.Items, lineItem{SKU: "B"}) }, "items[1].qty"},
+	}
+
+	v := New()
+	for _, e := range tests {
+		r := valid()
+		e.change(&r)
+
+		errs := v.Validate(context.Background(), &r)
+		if e.field == "" {
+			if len(errs) != 0 {
+				t.Errorf("%s: expected no errors but got %v", e.name, errs)
+			}
+			continue
+		}
+
+		if len(errs) != 1 || errs[e.field] == "" {
+			t.Errorf("%s: expected an error for %s but got %v", e.name, e.field, errs)
+		}
+	}
+}
+
+func TestValidator_Register(t *testing.T) {
+	type signup struct {
+		Code string `form:"code" validate:"required|even"`
+	}
+
+	v := New()
+	v.Register("even", func(_ context.Context, f Field) string {
+		if len(f.String())%2 != 0 {
+			return "Must have an even length"
+		}
+		return ""
+	})
+
+	errs := v.Validate(context.Background(), signup{Code: "abc"})
+	if errs["code"] != "Must have an even length" {
+		t.Errorf("custom rule not applied: %v", errs)
+	}
+}
+
+func TestValidator_UnknownRule(t *testing.T) {
+	type signup struct {
+		Code string `validate:"required|nope"`
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "nope") {
+			t.Error("expected a panic for an unknown rule, got", r)
+		}
+	}()
+
+	New().Validate(context.Background(), signup{Code: "x"})
+}
@@ -1,10 +1,13 @@
 package cache
 
 import (
+	"errors"
 	"github.com/dgraph-io/badger/v4"
 	"time"
 )
 
+var errExists = errors.New("key exists")
+
 type BadgerCache struct {
 	Conn   *badger.DB
 	Prefix string
@@ -23,6 +26,9 @@ func (b *BadgerCache) Get(str string) (interface{}, error) {
 
 	err := b.Conn.View(func(txn *badger.Txn) error {
 		item, err := txn.Get([]byte(str))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrNotFound
+		}
 		if err != nil {
 			return err
 		}
@@ -82,6 +88,37 @@ func (b *BadgerCache) Set(str string, value interface{}, expireSecs ...int) erro
 	return nil
 }
 
+// Add creates an entry with an optional expiry time in seconds, only when str is not in the cache.
+// It reports whether the entry was created
+func (b *BadgerCache) Add(str string, value interface{}, expireSecs ...int) (bool, error) {
+	encoded, err := encode(Entry{str: value})
+	if err != nil {
+		return false, err
+	}
+
+	err = b.Conn.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(str))
+		if err == nil {
+			return errExists
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		e := badger.NewEntry([]byte(str), encoded)
+		if len(expireSecs) > 0 {
+			e = e.WithTTL(time.Second * time.Duration(expireSecs[0]))
+		}
+		return txn.SetEntry(e)
+	})
+
+	// a transaction that added the key at the same time makes the commit conflict
+	if errors.Is(err, errExists) || errors.Is(err, badger.ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (b *BadgerCache) Forget(str string) error {
 	err := b.Conn.Update(func(txn *badger.Txn) error {
 		err := txn.Delete([]byte(str))
@@ -146,3 +183,54 @@ func (b *BadgerCache) emptyByMatch(str string) error {
 
 	return err
 }
+
+// Update calls fn with the value of every entry whose key starts with prefix, and replaces the
+// value when fn returns true, keeping the entry's expiry. It returns the number of entries replaced
+func (b *BadgerCache) Update(prefix string, fn func(key string, value interface{}) (interface{}, bool)) (int, error) {
+	updated := 0
+	err := b.Conn.Update(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			item := it.Item()
+			key := string(item.Key())
+
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			decoded, err := decode(string(val))
+			if err != nil {
+				// not written by the cache
+				continue
+			}
+
+			value, changed := fn(key, decoded[key])
+			if !changed {
+				continue
+			}
+
+			encoded, err := encode(Entry{key: value})
+			if err != nil {
+				return err
+			}
+
+			e := badger.NewEntry(item.KeyCopy(nil), encoded)
+			if expiresAt := item.ExpiresAt(); expiresAt > 0 {
+				e.ExpiresAt = expiresAt
+			}
+
+			err = txn.SetEntry(e)
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+
+		return nil
+	})
+
+	return updated, err
+}
@@ -1,6 +1,11 @@
 package cache
 
-import "testing"
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
 
 func TestBadgerCache_Has(t *testing.T) {
 	err := testBadgerCache.Forget("foo")
@@ -9,7 +14,7 @@ func TestBadgerCache_Has(t *testing.T) {
 	}
 
 	inCache, err := testBadgerCache.Has("foo")
-	if err != nil && err.Error() != "Key not found" {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Error(err)
 	}
 
@@ -33,6 +38,17 @@ func TestBadgerCache_Has(t *testing.T) {
 	}
 }
 
+func TestBadgerCache_ErrNotFound(t *testing.T) {
+	_ = testBadgerCache.Forget("missing")
+
+	if _, err := testBadgerCache.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound from Get, got", err)
+	}
+	if inCache, err := testBadgerCache.Has("missing"); inCache || !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound from Has, got", inCache, err)
+	}
+}
+
 func TestBadgerCache_Get(t *testing.T) {
 	err := testBadgerCache.Set("foo", "bar")
 	if err != nil {
@@ -61,7 +77,7 @@ func TestBadgerCache_Forget(t *testing.T) {
 	}
 
 	inCache, err := testBadgerCache.Has("foo")
-	if err != nil && err.Error() != "Key not found" {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Error(err)
 	}
 
@@ -82,7 +98,7 @@ func TestBadgerCache_Empty(t *testing.T) {
 	}
 
 	inCache, err := testBadgerCache.Has("foo")
-	if err != nil && err.Error() != "Key not found" {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Error(err)
 	}
 
@@ -113,7 +129,7 @@ func TestBadgerCache_EmptyByMatch(t *testing.T) {
 	}
 
 	inCache, err := testBadgerCache.Has("alpha")
-	if err != nil && err.Error() != "Key not found" {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Error(err)
 	}
 
@@ -122,7 +138,7 @@ func TestBadgerCache_EmptyByMatch(t *testing.T) {
 	}
 
 	inCache, err = testBadgerCache.Has("alpha2")
-	if err != nil && err.Error() != "Key not found" {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Error(err)
 	}
 
@@ -131,7 +147,7 @@ func TestBadgerCache_EmptyByMatch(t *testing.T) {
 	}
 
 	inCache, err = testBadgerCache.Has("beta")
-	if err != nil && err.Error() != "Key not found" {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Error(err)
 	}
 
@@ -139,3 +155,59 @@ func TestBadgerCache_EmptyByMatch(t *testing.T) {
 		t.Error("beta not found in cache and it should be there")
 	}
 }
+
+func TestBadgerCache_Update(t *testing.T) {
+	_ = testBadgerCache.Set("update_a", "old", 3600)
+	_ = testBadgerCache.Set("update_b", "keep")
+	_ = testBadgerCache.Set("other", "old")
+
+	updated, err := testBadgerCache.Update("update_", func(key string, value interface{}) (interface{}, bool) {
+		if value == "old" {
+			return "new", true
+		}
+		return nil, false
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if updated != 1 {
+		t.Error("expected 1 entry to be updated, got", updated)
+	}
+
+	for key, expected := range map[string]string{"update_a": "new", "update_b": "keep", "other": "old"} {
+		value, _ := testBadgerCache.Get(key)
+		if value != expected {
+			t.Errorf("expected %s for %s but got %v", expected, key, value)
+		}
+	}
+
+	_ = testBadgerCache.Empty()
+}
+
+func TestBadgerCache_Add(t *testing.T) {
+	_ = testBadgerCache.Forget("once")
+
+	var added atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := testBadgerCache.Add("once", true, 60)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				added.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if added.Load() != 1 {
+		t.Error("expected the entry to be added once, got", added.Load())
+	}
+
+	_ = testBadgerCache.Forget("once")
+}
@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/gob"
+	"errors"
 )
 
 var ctx = context.Background()
 
+// ErrNotFound is returned by Get and Has when a key is not in the cache. Its message is the one
+// the caches returned before, for code that still compares it
+var ErrNotFound = errors.New("Key not found")
+
+// Cache stores values by key. Get and Has return ErrNotFound for a key that is not set, which
+// callers check with errors.Is
 type Cache interface {
 	Has(string) (bool, error)
 	Get(string) (interface{}, error)
@@ -17,6 +24,12 @@ type Cache interface {
 	Empty() error
 }
 
+// Adder is implemented by caches that can add an entry only when its key is not set. It is
+// atomic, so when several callers add the same key only one of them succeeds
+type Adder interface {
+	Add(string, interface{}, ...int) (bool, error)
+}
+
 type Entry map[string]interface{}
 
 func encode(item Entry) ([]byte, error) {
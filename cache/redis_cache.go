@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+// RedisCache keeps the cache in redis, so it is shared by every instance of the application.
+// Values are gob encoded like BadgerCache values, and keys are prefixed with Prefix
+type RedisCache struct {
+	Conn   *redis.Client
+	Prefix string
+}
+
+func (c *RedisCache) key(str string) string {
+	if c.Prefix == "" {
+		return str
+	}
+	return c.Prefix + ":" + str
+}
+
+func (c *RedisCache) Has(str string) (bool, error) {
+	n, err := c.Conn.Exists(ctx, c.key(str)).Result()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, ErrNotFound
+	}
+	return true, nil
+}
+
+func (c *RedisCache) Get(str string) (interface{}, error) {
+	fromCache, err := c.Conn.Get(ctx, c.key(str)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := decode(fromCache)
+	if err != nil {
+		return nil, err
+	}
+	return decoded[str], nil
+}
+
+// Set creates an entry in the cache with an optional expiry time in seconds
+func (c *RedisCache) Set(str string, value interface{}, expireSecs ...int) error {
+	encoded, err := encode(Entry{str: value})
+	if err != nil {
+		return err
+	}
+
+	return c.Conn.Set(ctx, c.key(str), encoded, expiry(expireSecs)).Err()
+}
+
+// Add creates an entry with an optional expiry time in seconds, only when str is not in the cache.
+// It reports whether the entry was created
+func (c *RedisCache) Add(str string, value interface{}, expireSecs ...int) (bool, error) {
+	encoded, err := encode(Entry{str: value})
+	if err != nil {
+		return false, err
+	}
+
+	return c.Conn.SetNX(ctx, c.key(str), encoded, expiry(expireSecs)).Result()
+}
+
+func (c *RedisCache) Forget(str string) error {
+	return c.Conn.Del(ctx, c.key(str)).Err()
+}
+
+// EmptyByMatch removes the entries whose key starts with str
+func (c *RedisCache) EmptyByMatch(str string) error {
+	iter := c.Conn.Scan(ctx, 0, c.key(str)+"*", 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 1000 {
+			if err := c.Conn.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		return c.Conn.Del(ctx, keys...).Err()
+	}
+	return nil
+}
+
+func (c *RedisCache) Empty() error {
+	return c.EmptyByMatch("")
+}
+
+// Update calls fn with the value of every entry whose key starts with prefix, and replaces the
+// value when fn returns true, keeping the entry's expiry. An entry that is changed while fn runs
+// is left as it is. It returns the number of entries replaced
+func (c *RedisCache) Update(prefix string, fn func(key string, value interface{}) (interface{}, bool)) (int, error) {
+	updated := 0
+	iter := c.Conn.Scan(ctx, 0, c.key(prefix)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		redisKey := iter.Val()
+		key := strings.TrimPrefix(redisKey, c.key(""))
+
+		err := c.Conn.Watch(ctx, func(tx *redis.Tx) error {
+			fromCache, err := tx.Get(ctx, redisKey).Result()
+			if err != nil {
+				// expired since the scan, or not a string
+				return nil
+			}
+
+			decoded, err := decode(fromCache)
+			if err != nil {
+				// not written by the cache
+				return nil
+			}
+
+			value, changed := fn(key, decoded[key])
+			if !changed {
+				return nil
+			}
+
+			encoded, err := encode(Entry{key: value})
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(ctx, redisKey, encoded, redis.SetArgs{KeepTTL: true, Mode: "XX"})
+				return nil
+			})
+			if err == nil {
+				updated++
+			}
+			return err
+		}, redisKey)
+
+		if err != nil && !errors.Is(err, redis.TxFailedErr) && !errors.Is(err, redis.Nil) {
+			return updated, err
+		}
+	}
+
+	return updated, iter.Err()
+}
+
+// expiry converts the optional expiry in seconds of Set and Add, 0 is no expiry
+func expiry(expireSecs []int) time.Duration {
+	if len(expireSecs) == 0 {
+		return 0
+	}
+	return time.Duration(expireSecs[0]) * time.Second
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRedisCache(t *testing.T) {
+	if testRedisCache == nil {
+		t.Skip("redis is not available")
+	}
+	c := testRedisCache
+
+	_ = c.Set("foo", "bar", 60)
+	if inCache, _ := c.Has("foo"); !inCache {
+		t.Error("foo not found in cache, and it should be there")
+	}
+	if x, _ := c.Get("foo"); x != "bar" {
+		t.Error("did not get correct value from cache:", x)
+	}
+
+	if added, _ := c.Add("foo", "other"); added {
+		t.Error("entry added for a key that is set")
+	}
+	if added, _ := c.Add("once", true, 60); !added {
+		t.Error("entry not added")
+	}
+
+	_ = c.Set("beta", "beta")
+	_ = c.EmptyByMatch("f")
+	if inCache, _ := c.Has("foo"); inCache {
+		t.Error("foo found in cache and it shouldn't be there")
+	}
+	if inCache, _ := c.Has("beta"); !inCache {
+		t.Error("beta not found in cache and it should be there")
+	}
+
+	_ = c.Empty()
+	if inCache, _ := c.Has("beta"); inCache {
+		t.Error("beta found in cache and it shouldn't be there")
+	}
+
+	if _, err := c.Get("beta"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound from Get, got", err)
+	}
+	if _, err := c.Has("beta"); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound from Has, got", err)
+	}
+}
+
+func TestRedisCache_Update(t *testing.T) {
+	if testRedisCache == nil {
+		t.Skip("redis is not available")
+	}
+	c := testRedisCache
+
+	_ = c.Set("update_a", "old", 3600)
+	_ = c.Set("update_b", "keep")
+	_ = c.Set("other", "old")
+
+	updated, err := c.Update("update_", func(key string, value interface{}) (interface{}, bool) {
+		if value == "old" {
+			return "new", true
+		}
+		return nil, false
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if updated != 1 {
+		t.Error("expected 1 entry to be updated, got", updated)
+	}
+
+	for key, expected := range map[string]string{"update_a": "new", "update_b": "keep", "other": "old"} {
+		value, _ := c.Get(key)
+		if value != expected {
+			t.Errorf("expected %s for %s but got %v", expected, key, value)
+		}
+	}
+
+	if ttl := c.Conn.TTL(ctx, c.key("update_a")).Val(); ttl <= 0 {
+		t.Error("expiry not kept, got", ttl)
+	}
+
+	_ = c.Empty()
+}
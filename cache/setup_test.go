@@ -2,6 +2,8 @@ package cache
 
 import (
 	"github.com/dgraph-io/badger/v4"
+	"github.com/ory/dockertest/v3"
+	"github.com/redis/go-redis/v9"
 	"log"
 	"os"
 	"testing"
@@ -9,6 +11,9 @@ import (
 
 var testBadgerCache BadgerCache
 
+// testRedisCache is nil when docker is not available to run redis
+var testRedisCache *RedisCache
+
 func TestMain(m *testing.M) {
 
 	// clear and create badger database
@@ -27,5 +32,42 @@ func TestMain(m *testing.M) {
 	db, _ := badger.Open(badger.DefaultOptions("./testdata/tmp/badger"))
 	testBadgerCache.Conn = db
 
-	os.Exit(m.Run())
+	pool, resource := startRedis()
+	code := m.Run()
+	if resource != nil {
+		_ = pool.Purge(resource)
+	}
+
+	os.Exit(code)
+}
+
+// startRedis runs redis in docker for the RedisCache tests, which are skipped without docker
+func startRedis() (*dockertest.Pool, *dockertest.Resource) {
+	pool, err := dockertest.NewPool("")
+	if err == nil {
+		err = pool.Client.Ping()
+	}
+	if err != nil {
+		log.Println("docker is not available, skipping the redis tests:", err)
+		return nil, nil
+	}
+
+	resource, err := pool.Run("redis", "7-alpine", nil)
+	if err != nil {
+		log.Println("could not start redis:", err)
+		return nil, nil
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: resource.GetHostPort("6379/tcp")})
+	err = pool.Retry(func() error {
+		return client.Ping(ctx).Err()
+	})
+	if err != nil {
+		log.Println("redis did not start:", err)
+		_ = pool.Purge(resource)
+		return nil, nil
+	}
+
+	testRedisCache = &RedisCache{Conn: client, Prefix: "test"}
+	return pool, resource
 }
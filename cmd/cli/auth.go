@@ -98,6 +98,9 @@ func doAuth() error {
 	color.Yellow("  - auth middleware created")
 	color.Yellow("")
 	color.Yellow("  - Don't forget to add user and token models in data/models.go, and to add appropriate middleware to your routes!")
+	color.Yellow("  - The auth handlers use named routes, so name your login and password reset routes with")
+	color.Yellow("    Name(\"login\", \"/users/login\") and Name(\"reset-password\", \"/users/reset-password\")")
+	color.Yellow("  - Password reset links can only be used once, which needs a cache: set CACHE in .env")
 
 	return nil
 }
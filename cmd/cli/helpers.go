@@ -63,10 +63,23 @@ func showHelp() {
 
     help                     - show help
     version                  - print version
+    down                     - puts the application in maintenance mode
+        --allow <ip>             - an IP address or CIDR range that can still use the application
+        --secret <secret>        - visiting /<secret> bypasses maintenance mode
+        --retry <seconds>        - sent to clients in the Retry-After header
+    up                       - takes the application out of maintenance mode
+    key rotate               - replaces KEY with a new key, keeping the old key in PREVIOUS_KEYS
+        --keep <duration>        - how long URLs signed with the old key are accepted, e.g. 72h (default 168h)
+        --reencrypt              - encrypts ENCRYPTED_COLUMNS and cache entries again with the new key,
+                                   and drops the previous keys whose cutoff has passed
+    mail list [state]        - lists the messages in MAIL_QUEUE, optionally only queued or failed ones
+    mail retry <id|all>      - queues a failed message again, or all failed messages
+    mail purge <state|all>   - removes the queued or failed messages in MAIL_QUEUE, or all of them
     make auth                - creates authentication tables, models and middleware
     make handler <name>      - creates a stub handler in the handlers directory
     make key                 - creates a random 32 character encryption key
     make mail <name>         - creates starter templates for text and html emails in the mail directory
+    make mailqueue           - creates a new table as a mail queue store, for MAIL_QUEUE=database
     make model <name>        - creates a new model in the data directory
     make session             - creates a new table as a session store
     
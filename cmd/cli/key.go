@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/fouched/rapidus"
+	"github.com/fouched/rapidus/encryption"
+	"os"
+	"strings"
+	"time"
+)
+
+func doKey(args []string) error {
+	if len(args) == 0 || args[0] != "rotate" {
+		return errors.New("key requires a subcommand: (rotate)")
+	}
+
+	return doKeyRotate(args[1:])
+}
+
+// doKeyRotate replaces KEY with a new key, and keeps the old key in PREVIOUS_KEYS until the cutoff
+func doKeyRotate(args []string) error {
+	flags := flag.NewFlagSet("key rotate", flag.ContinueOnError)
+	keep := flags.Duration("keep", 7*24*time.Hour, "how long URLs signed with the old key stay valid")
+	reencrypt := flags.Bool("reencrypt", false, "encrypt ENCRYPTED_COLUMNS and cache entries again with the new key, and drop expired keys")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	oldKey := os.Getenv("KEY")
+	if oldKey == "" {
+		return errors.New("there is no KEY in .env to rotate")
+	}
+
+	previous, err := rapidus.ParsePreviousKeys(os.Getenv("PREVIOUS_KEYS"))
+	if err != nil {
+		return err
+	}
+
+	// the old key is kept until its cutoff. Keys whose cutoff has passed are kept as well, as the
+	// values encrypted with them can't be read without them, until they are encrypted again
+	keys := append([]rapidus.PreviousKey{{Key: oldKey, Until: time.Now().Add(*keep)}}, previous...)
+
+	newKey := rap.RandomString(32)
+	err = setEnvValues(rap.RootPath+"/.env", map[string]string{
+		"KEY":           newKey,
+		"PREVIOUS_KEYS": rapidus.FormatPreviousKeys(keys),
+	})
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("KEY rotated, the old key is accepted until %s", keys[0].Until.Format(time.RFC1123))
+
+	active := unexpiredKeys(keys, time.Now())
+	if !*reencrypt {
+		if expired := len(keys) - len(active); expired > 0 {
+			color.Yellow("%d expired keys are kept to re-encrypt values, run rapidus key rotate --reencrypt to drop them", expired)
+		}
+		return nil
+	}
+
+	oldKeys := make([][]byte, len(keys))
+	for i, k := range keys {
+		oldKeys[i] = []byte(k.Key)
+	}
+
+	err = reencryptData(newKey, oldKeys)
+	if err != nil {
+		return err
+	}
+
+	// nothing is encrypted with the expired keys anymore
+	return setEnvValues(rap.RootPath+"/.env", map[string]string{
+		"PREVIOUS_KEYS": rapidus.FormatPreviousKeys(active),
+	})
+}
+
+// unexpiredKeys returns the keys whose cutoff has not passed
+func unexpiredKeys(keys []rapidus.PreviousKey, now time.Time) []rapidus.PreviousKey {
+	var active []rapidus.PreviousKey
+	for _, k := range keys {
+		if now.Before(k.Until) {
+			active = append(active, k)
+		}
+	}
+	return active
+}
+
+// reencryptData encrypts the ENCRYPTED_COLUMNS and cache entries again with the new key
+func reencryptData(newKey string, oldKeys [][]byte) error {
+	e, err := encryption.New([]byte(newKey), oldKeys...)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if columns := os.Getenv("ENCRYPTED_COLUMNS"); columns != "" {
+		db, err := rap.OpenDB(rap.DB.Type, rap.BuildDSN())
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		rap.DB.Pool = db
+
+		updated, err := rap.ReencryptColumns(ctx, e, strings.Split(columns, ","))
+		if err != nil {
+			return err
+		}
+		color.Yellow("Re-encrypted %d database values", updated)
+	}
+
+	updated, err := rap.ReencryptCache(ctx, e)
+	if err != nil {
+		return err
+	}
+	color.Yellow("Re-encrypted %d cache entries", updated)
+
+	return nil
+}
+
+// setEnvValues replaces the values of variables in an env file, and adds the variables
+// that are not in it yet
+func setEnvValues(path string, values map[string]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	done := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, _, found := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+
+		if value, ok := values[name]; found && ok && !done[name] {
+			line = fmt.Sprintf("%s=%s", name, value)
+			done[name] = true
+		}
+		lines = append(lines, line)
+	}
+
+	for name, value := range values {
+		if !done[name] {
+			lines = append(lines, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
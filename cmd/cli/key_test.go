@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/fouched/rapidus"
+	"github.com/joho/godotenv"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDoKeyRotate(t *testing.T) {
+	now := time.Now()
+	expired := rapidus.PreviousKey{Key: "expiredexpiredexpiredexpiredexpi", Until: now.Add(-time.Hour)}
+	active := rapidus.PreviousKey{Key: "activeactiveactiveactiveactiveac", Until: now.Add(time.Hour)}
+	oldKey := "oldkeyoldkeyoldkeyoldkeyoldkeyol"
+
+	rotate := func(args ...string) map[string]string {
+		rap.RootPath = t.TempDir()
+		env := "KEY=" + oldKey + "\nPREVIOUS_KEYS=" + rapidus.FormatPreviousKeys([]rapidus.PreviousKey{active, expired}) + "\n"
+		if err := os.WriteFile(rap.RootPath+"/.env", []byte(env), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		t.Setenv("KEY", oldKey)
+		t.Setenv("PREVIOUS_KEYS", rapidus.FormatPreviousKeys([]rapidus.PreviousKey{active, expired}))
+		t.Setenv("ENCRYPTED_COLUMNS", "")
+		t.Setenv("CACHE", "")
+
+		if err := doKeyRotate(args); err != nil {
+			t.Fatal(err)
+		}
+
+		values, err := godotenv.Read(rap.RootPath + "/.env")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return values
+	}
+
+	values := rotate()
+	if len(values["KEY"]) != 32 || values["KEY"] == oldKey {
+		t.Errorf("expected a new 32 character KEY, got %q", values["KEY"])
+	}
+	keys, err := rapidus.ParsePreviousKeys(values["PREVIOUS_KEYS"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[0].Key != oldKey || keys[1].Key != active.Key || keys[2].Key != expired.Key {
+		t.Errorf("expected the old, active and expired keys to be kept, got %v", keys)
+	}
+
+	values = rotate("--reencrypt")
+	keys, err = rapidus.ParsePreviousKeys(values["PREVIOUS_KEYS"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Key != oldKey || keys[1].Key != active.Key {
+		t.Errorf("expected the expired key to be dropped after re-encrypting, got %v", keys)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/fouched/rapidus/mailer"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func doMail(args []string) error {
+	if len(args) == 0 {
+		return errors.New("mail requires a subcommand: (list|retry|purge)")
+	}
+
+	if os.Getenv("MAIL_QUEUE") == "" {
+		return errors.New("MAIL_QUEUE is not set, queued mail is only kept in memory")
+	}
+
+	if os.Getenv("MAIL_QUEUE") == "database" {
+		db, err := rap.OpenDB(rap.DB.Type, rap.BuildDSN())
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		rap.DB.Pool = db
+	}
+
+	store, closeStore, err := rap.OpenMailQueue()
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	arg := ""
+	if len(args) > 1 {
+		arg = args[1]
+	}
+
+	switch args[0] {
+	case "list":
+		return doMailList(ctx, store, mailer.State(arg))
+	case "retry":
+		if arg == "" {
+			return errors.New("mail retry requires the id of a failed message, or all")
+		}
+		if arg == "all" {
+			arg = ""
+		}
+
+		n, err := store.Retry(ctx, arg)
+		if err != nil {
+			return err
+		}
+		color.Yellow("%d failed messages queued again", n)
+	case "purge":
+		if arg == "" {
+			return errors.New("mail purge requires the state of the messages to remove: (queued|failed|all)")
+		}
+		if arg == "all" {
+			arg = ""
+		}
+
+		n, err := store.Purge(ctx, mailer.State(arg))
+		if err != nil {
+			return err
+		}
+		color.Yellow("%d messages removed", n)
+	default:
+		return errors.New("mail requires a subcommand: (list|retry|purge)")
+	}
+
+	return nil
+}
+
+func doMailList(ctx context.Context, store mailer.QueueStore, state mailer.State) error {
+	messages, err := store.List(ctx, state)
+	if err != nil {
+		return err
+	}
+
+	if len(messages) == 0 {
+		color.Yellow("No messages")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tATTEMPTS\tCREATED\tTO\tSUBJECT\tLAST ERROR")
+	for _, qm := range messages {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", qm.Message.ID, qm.State, qm.Attempts,
+			qm.CreatedAt.Format(time.DateTime), qm.Message.To, qm.Message.Subject, qm.LastError)
+	}
+
+	return w.Flush()
+}
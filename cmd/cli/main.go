@@ -39,6 +39,26 @@ func main() {
 			exitGracefully(err)
 		}
 		message = "Migrations complete!"
+	case "down":
+		err = doDown(os.Args[2:])
+		if err != nil {
+			exitGracefully(err)
+		}
+	case "up":
+		err = doUp()
+		if err != nil {
+			exitGracefully(err)
+		}
+	case "key":
+		err = doKey(os.Args[2:])
+		if err != nil {
+			exitGracefully(err)
+		}
+	case "mail":
+		err = doMail(os.Args[2:])
+		if err != nil {
+			exitGracefully(err)
+		}
 	case "make":
 		if arg2 == "" {
 			exitGracefully(errors.New("make requires a subcommand: (migration|model|handler)"))
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"github.com/fatih/color"
+	"github.com/fouched/rapidus"
+	"os"
+	"strings"
+)
+
+func doDown(args []string) error {
+	var m rapidus.Maintenance
+
+	flags := flag.NewFlagSet("down", flag.ContinueOnError)
+	flags.Func("allow", "IP address or CIDR range that can still use the application (repeatable)", func(s string) error {
+		for _, ip := range strings.Split(s, ",") {
+			m.Allow = append(m.Allow, strings.TrimSpace(ip))
+		}
+		return nil
+	})
+	flags.StringVar(&m.Secret, "secret", "", "visiting /<secret> sets a cookie that bypasses maintenance mode")
+	flags.IntVar(&m.Retry, "retry", 0, "seconds sent in the Retry-After header")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = rap.DownForMaintenance(m)
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("Application is now in maintenance mode.")
+	if m.Secret != "" {
+		color.Yellow("Bypass maintenance mode by visiting %s/%s", strings.TrimSuffix(os.Getenv("APP_URL"), "/"), m.Secret)
+	}
+
+	return nil
+}
+
+func doUp() error {
+	err := rap.UpFromMaintenance()
+	if err != nil {
+		return err
+	}
+
+	color.Yellow("Application is now live.")
+	return nil
+}
@@ -114,6 +114,11 @@ func doMake(arg2, arg3 string) error {
 		if err != nil {
 			exitGracefully(err)
 		}
+	case "mailqueue":
+		err := doMailQueueTable()
+		if err != nil {
+			exitGracefully(err)
+		}
 
 	}
 
@@ -44,15 +44,11 @@ func doNew(appName string) {
 
 	// create a ready to go .env file
 	color.Yellow("\tCreating .env file...")
-	data, err := templateFS.ReadFile("templates/env.txt")
+	env, err := envFile(appName)
 	if err != nil {
 		exitGracefully(err)
 	}
 
-	env := string(data)
-	env = strings.ReplaceAll(env, "${APP_NAME}", appName)
-	env = strings.ReplaceAll(env, "${KEY}", rap.RandomString(32))
-
 	err = copyDataToFile([]byte(env), fmt.Sprintf("./%s/.env", appName))
 	if err != nil {
 		exitGracefully(err)
@@ -86,7 +82,7 @@ func doNew(appName string) {
 	color.Yellow("\tCreating go.mod file...")
 	_ = os.Remove(fmt.Sprintf("./%s/go.mod", appName))
 
-	data, err = templateFS.ReadFile("templates/go.mod.txt")
+	data, err := templateFS.ReadFile("templates/go.mod.txt")
 	if err != nil {
 		exitGracefully(err)
 	}
@@ -112,3 +108,17 @@ func doNew(appName string) {
 	color.Green("Done building " + appURL)
 	color.Green("Go build something awesome!")
 }
+
+// envFile returns the .env file of a new application, with a new KEY
+func envFile(appName string) (string, error) {
+	data, err := templateFS.ReadFile("templates/env.txt")
+	if err != nil {
+		return "", err
+	}
+
+	env := string(data)
+	env = strings.ReplaceAll(env, "${APP_NAME}", appName)
+	env = strings.ReplaceAll(env, "${KEY}", rap.RandomString(32))
+
+	return env, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/joho/godotenv"
+	"testing"
+)
+
+func TestEnvFile(t *testing.T) {
+	env, err := envFile("myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := godotenv.Unmarshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values["KEY"]) != 32 {
+		t.Errorf("expected a 32 character KEY, got %q", values["KEY"])
+	}
+	if values["PREVIOUS_KEYS"] != "" {
+		t.Errorf("expected no previous keys, got %q", values["PREVIOUS_KEYS"])
+	}
+	if values["APP_NAME"] != "myapp" {
+		t.Errorf("expected the app name, got %q", values["APP_NAME"])
+	}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE mail_queue (
+    id VARCHAR(36) PRIMARY KEY,
+    payload TEXT NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at BIGINT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX mail_queue_state_available_at_idx ON mail_queue (state, available_at);
\ No newline at end of file
@@ -0,0 +1,25 @@
+package rapidus
+
+import (
+	"github.com/fouched/rapidus/compress"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// Compress creates a compression middleware, for use on a route group when the global
+// settings in .env do not suit, e.g. with a different minimum size
+func (r *Rapidus) Compress(opts compress.Options) func(http.Handler) http.Handler {
+	return compress.Handler(opts)
+}
+
+// compressOptionsFromEnv reads the global compression settings, and reports whether compression is on
+func compressOptionsFromEnv() (compress.Options, bool) {
+	enabled, _ := strconv.ParseBool(os.Getenv("COMPRESSION"))
+	minSize, _ := strconv.Atoi(os.Getenv("COMPRESSION_MIN_SIZE"))
+
+	return compress.Options{
+		MinSize:   minSize,
+		Encodings: envList("COMPRESSION_ENCODINGS"),
+	}, enabled
+}
@@ -0,0 +1,142 @@
+package compress
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var page = strings.Repeat("<p>rapidus</p>", 200)
+
+func serve(t *testing.T, handler http.HandlerFunc, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	Handler(Options{})(handler).ServeHTTP(w, r)
+	return w
+}
+
+func html(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	var r io.Reader
+	var err error
+
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "br":
+		r = brotli.NewReader(body)
+	case "zstd":
+		r, err = zstd.NewReader(body)
+	default:
+		r = body
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestHandler_Encodings(t *testing.T) {
+	var tests = []struct {
+		accept   string
+		expected string
+	}{
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"gzip, br, zstd", "zstd"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"*", "zstd"},
+		{"deflate", ""},
+		{"", ""},
+	}
+
+	for _, e := range tests {
+		w := serve(t, html(page), map[string]string{"Accept-Encoding": e.accept})
+
+		if w.Header().Get("Content-Encoding") != e.expected {
+			t.Errorf("%q: expected encoding %q but got %q", e.accept, e.expected, w.Header().Get("Content-Encoding"))
+		}
+
+		if decode(t, e.expected, w.Body) != page {
+			t.Errorf("%q: body not decoded correctly", e.accept)
+		}
+	}
+}
+
+func TestHandler_Skipped(t *testing.T) {
+	var tests = []struct {
+		name    string
+		handler http.HandlerFunc
+		headers map[string]string
+	}{
+		{"small response", html("<p>small</p>"), nil},
+		{"image", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = io.WriteString(w, page)
+		}, nil},
+		{"range request", html(page), map[string]string{"Range": "bytes=0-10"}},
+		{"already encoded", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = io.WriteString(w, page)
+		}, nil},
+	}
+
+	for _, e := range tests {
+		if e.headers == nil {
+			e.headers = map[string]string{}
+		}
+		e.headers["Accept-Encoding"] = "gzip"
+
+		w := serve(t, e.handler, e.headers)
+		if w.Header().Get("Content-Encoding") == "gzip" && e.name != "already encoded" {
+			t.Errorf("%s: response was compressed", e.name)
+		}
+
+		if e.name != "already encoded" && w.Body.Len() == 0 {
+			t.Errorf("%s: body lost", e.name)
+		}
+	}
+}
+
+func TestHandler_Streaming(t *testing.T) {
+	w := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, "<p>first</p>")
+		w.(http.Flusher).Flush()
+		_, _ = io.WriteString(w, "<p>second</p>")
+	}, map[string]string{"Accept-Encoding": "gzip"})
+
+	if w.Code != http.StatusAccepted {
+		t.Error("status lost, got", w.Code)
+	}
+
+	if !w.Flushed {
+		t.Error("flush not passed on")
+	}
+
+	if decode(t, w.Header().Get("Content-Encoding"), w.Body) != "<p>first</p><p>second</p>" {
+		t.Error("streamed body not decoded correctly")
+	}
+}
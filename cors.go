@@ -0,0 +1,41 @@
+package rapidus
+
+import (
+	"github.com/fouched/rapidus/cors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CORS creates a CORS middleware, for use on a route group when the rules differ from
+// the global rules in .env
+func (r *Rapidus) CORS(opts cors.Options) func(http.Handler) http.Handler {
+	return cors.Handler(opts)
+}
+
+// corsOptionsFromEnv reads the global CORS rules. CORS is disabled when no origins are allowed
+func corsOptionsFromEnv() cors.Options {
+	maxAge, _ := strconv.Atoi(os.Getenv("CORS_MAX_AGE"))
+	credentials, _ := strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS"))
+
+	return cors.Options{
+		AllowedOrigins:   envList("CORS_ALLOWED_ORIGINS"),
+		AllowedMethods:   envList("CORS_ALLOWED_METHODS"),
+		AllowedHeaders:   envList("CORS_ALLOWED_HEADERS"),
+		ExposedHeaders:   envList("CORS_EXPOSED_HEADERS"),
+		AllowCredentials: credentials,
+		MaxAge:           maxAge,
+	}
+}
+
+// envList reads a comma separated list from .env
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,153 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Options configures which cross origin requests are allowed
+type Options struct {
+	// AllowedOrigins may contain "*" to allow any origin, or a wildcard subdomain, e.g. "https://*.example.com".
+	// Origins only allowed by "*" are never allowed credentials
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long, in seconds, browsers may cache a preflight response
+	MaxAge int
+}
+
+var defaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+
+var defaultHeaders = []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"}
+
+// Handler creates the CORS middleware. Preflight requests are answered directly and do not
+// reach the next handler
+func Handler(opts Options) func(http.Handler) http.Handler {
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = defaultMethods
+	}
+
+	if len(opts.AllowedHeaders) == 0 {
+		opts.AllowedHeaders = defaultHeaders
+	}
+
+	for i, m := range opts.AllowedMethods {
+		opts.AllowedMethods[i] = strings.ToUpper(m)
+	}
+
+	allowedHeaders := make(map[string]bool)
+	for _, h := range opts.AllowedHeaders {
+		allowedHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			allowed, anyOrigin := opts.originAllowed(origin)
+			if origin == "" || !allowed {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if preflight {
+				if !opts.methodAllowed(r.Header.Get("Access-Control-Request-Method")) ||
+					!headersAllowed(allowedHeaders, r.Header.Get("Access-Control-Request-Headers")) {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+
+				opts.setOrigin(h, origin, anyOrigin)
+				h.Set("Access-Control-Allow-Methods", strings.Join(opts.AllowedMethods, ", "))
+				h.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+				if opts.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			opts.setOrigin(h, origin, anyOrigin)
+			if len(opts.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setOrigin sets the allowed origin. An origin only allowed by "*" gets "*", which browsers don't
+// send credentials to, so that no site can make requests with the cookies of the user
+func (o *Options) setOrigin(h http.Header, origin string, anyOrigin bool) {
+	if anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	if o.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+}
+
+// originAllowed reports whether origin is allowed, and whether it is only allowed by "*"
+func (o *Options) originAllowed(origin string) (allowed bool, anyOrigin bool) {
+	origin = strings.ToLower(origin)
+	for _, allowed := range o.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" {
+			anyOrigin = true
+			continue
+		}
+		if allowed == origin {
+			return true, false
+		}
+
+		// wildcard subdomain, e.g. https://*.example.com
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok {
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true, false
+			}
+		}
+	}
+	return anyOrigin, anyOrigin
+}
+
+func (o *Options) methodAllowed(method string) bool {
+	method = strings.ToUpper(method)
+	for _, m := range o.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func headersAllowed(allowed map[string]bool, requested string) bool {
+	if requested == "" {
+		return true
+	}
+
+	for _, h := range strings.Split(requested, ",") {
+		if !allowed[http.CanonicalHeaderKey(strings.TrimSpace(h))] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testHandler = Handler(Options{
+	AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+	AllowedMethods:   []string{"GET", "POST", "DELETE"},
+	AllowCredentials: true,
+	MaxAge:           600,
+})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTeapot)
+}))
+
+func TestHandler_Preflight(t *testing.T) {
+	var tests = []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"allowed origin", "https://app.example.com", "DELETE", "Content-Type, Authorization", true},
+		{"wildcard subdomain", "https://api.example.org", "GET", "", true},
+		{"bare wildcard domain", "https://.example.org", "GET", "", false},
+		{"unknown origin", "https://evil.com", "GET", "", false},
+		{"method not allowed", "https://app.example.com", "PUT", "", false},
+		{"header not allowed", "https://app.example.com", "POST", "X-Custom", false},
+	}
+
+	for _, e := range tests {
+		r := httptest.NewRequest("OPTIONS", "/api/items", nil)
+		r.Header.Set("Origin", e.origin)
+		r.Header.Set("Access-Control-Request-Method", e.method)
+		r.Header.Set("Access-Control-Request-Headers", e.headers)
+
+		w := httptest.NewRecorder()
+		testHandler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("%s: preflight reached the handler", e.name)
+		}
+
+		got := w.Header().Get("Access-Control-Allow-Origin") == e.origin
+		if got != e.allowed {
+			t.Errorf("%s: expected allowed %v but got %v", e.name, e.allowed, got)
+		}
+
+		if e.allowed && w.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("%s: max age not set", e.name)
+		}
+	}
+}
+
+func TestHandler_Request(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/items", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+
+	w := httptest.NewRecorder()
+	testHandler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusTeapot {
+		t.Error("request did not reach the handler")
+	}
+
+	if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("credentials not allowed")
+	}
+
+	if w.Header().Get("Vary") != "Origin" {
+		t.Error("Vary header not set")
+	}
+}
+
+func TestHandler_AnyOrigin(t *testing.T) {
+	handler := Handler(Options{
+		AllowedOrigins:   []string{"*", "https://app.example.com"},
+		AllowCredentials: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/api/items", nil)
+	r.Header.Set("Origin", "https://evil.com")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("credentials allowed for an origin only allowed by *", w.Header())
+	}
+
+	r.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("credentials not allowed for a listed origin", w.Header())
+	}
+}
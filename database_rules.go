@@ -0,0 +1,190 @@
+package rapidus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/fouched/rapidus/binding"
+	"reflect"
+	"strings"
+)
+
+// Unique adds an error for field when value is already used in the column of table. When an
+// ignoreID is given, the row with that id is ignored, so a record being updated can keep its
+// own value. The field is not checked when it already has an error
+func (r *Rapidus) Unique(ctx context.Context, v *Validation, field, table, column string, value interface{}, ignoreID ...interface{}) error {
+	if _, exists := v.Errors[field]; exists {
+		return nil
+	}
+
+	var found bool
+	var err error
+	if len(ignoreID) > 0 {
+		found, err = r.rowExists(ctx, table, column, value, "id", ignoreID[0])
+	} else {
+		found, err = r.rowExists(ctx, table, column, value, "", nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	if found {
+		v.Errors[field] = "This value is already taken"
+	}
+
+	return nil
+}
+
+// Exists adds an error for field when value is not found in the column of table, e.g. to
+// check a foreign key. The field is not checked when it already has an error
+func (r *Rapidus) Exists(ctx context.Context, v *Validation, field, table, column string, value interface{}) error {
+	if _, exists := v.Errors[field]; exists {
+		return nil
+	}
+
+	found, err := r.rowExists(ctx, table, column, value, "", nil)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		v.Errors[field] = "The selected value is invalid"
+	}
+
+	return nil
+}
+
+// createRules creates the struct tag validator with the built-in rules and the database rules:
+//
+//	unique:users,email        the value must not be used in users.email yet
+//	unique:users,email,ID     as above, ignoring the row whose id is the struct's ID field
+//	unique:users,email,ID,uid as above, with uid as the id column
+//	exists:roles,id           the value must be found in roles.id
+func (r *Rapidus) createRules() *binding.Validator {
+	rules := binding.New()
+	rules.Register("unique", r.uniqueRule)
+	rules.Register("exists", r.existsRule)
+
+	return rules
+}
+
+func (r *Rapidus) uniqueRule(ctx context.Context, f binding.Field) string {
+	params := strings.Split(f.Param, ",")
+	if len(params) < 2 {
+		panic(fmt.Sprintf("rapidus: unique on field %s needs a table and a column", f.Name))
+	}
+
+	var idColumn string
+	var ignoreID interface{}
+	if len(params) > 2 {
+		id := f.Parent.FieldByName(params[2])
+		if !id.IsValid() {
+			panic(fmt.Sprintf("rapidus: unique on field %s refers to unknown field %s", f.Name, params[2]))
+		}
+		if id.Kind() == reflect.Pointer && !id.IsNil() {
+			id = id.Elem()
+		}
+
+		idColumn = "id"
+		if len(params) > 3 {
+			idColumn = params[3]
+		}
+
+		// a zero or nil id is a record that is being created, so there is nothing to ignore
+		if id.IsZero() {
+			idColumn = ""
+		} else {
+			ignoreID = id.Interface()
+		}
+	}
+
+	found, err := r.rowExists(ctx, params[0], params[1], f.Value.Interface(), idColumn, ignoreID)
+	if err != nil {
+		r.ErrorLog.Println(err)
+		return "This value could not be verified"
+	}
+
+	if found {
+		return "This value is already taken"
+	}
+	return ""
+}
+
+func (r *Rapidus) existsRule(ctx context.Context, f binding.Field) string {
+	params := strings.Split(f.Param, ",")
+	if len(params) != 2 {
+		panic(fmt.Sprintf("rapidus: exists on field %s needs a table and a column", f.Name))
+	}
+
+	found, err := r.rowExists(ctx, params[0], params[1], f.Value.Interface(), "", nil)
+	if err != nil {
+		r.ErrorLog.Println(err)
+		return "This value could not be verified"
+	}
+
+	if !found {
+		return "The selected value is invalid"
+	}
+	return ""
+}
+
+// rowExists reports whether table has a row where column equals value, ignoring the row whose
+// idColumn equals ignoreID when idColumn is not empty
+func (r *Rapidus) rowExists(ctx context.Context, table, column string, value interface{}, idColumn string, ignoreID interface{}) (bool, error) {
+	if r.DB.Pool == nil {
+		return false, errors.New("database validation rules need a database connection")
+	}
+
+	query, args := existsQuery(r.DB.Type, table, column, value, idColumn, ignoreID)
+
+	var one int
+	err := r.DB.Pool.QueryRowContext(ctx, query, args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func existsQuery(dbType, table, column string, value interface{}, idColumn string, ignoreID interface{}) (string, []interface{}) {
+	query := fmt.Sprintf("select 1 from %s where %s = %s",
+		quoteIdentifier(dbType, table), quoteIdentifier(dbType, column), placeholder(dbType, 1))
+	args := []interface{}{value}
+
+	if idColumn != "" {
+		query += fmt.Sprintf(" and %s <> %s", quoteIdentifier(dbType, idColumn), placeholder(dbType, 2))
+		args = append(args, ignoreID)
+	}
+
+	return query + " limit 1", args
+}
+
+// quoteIdentifier quotes a table or column name, which may be qualified by a schema,
+// e.g. public.users, for the database type
+func quoteIdentifier(dbType, name string) string {
+	quote := `"`
+	if dbType == "mysql" || dbType == "mariadb" {
+		quote = "`"
+	}
+
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quote + strings.ReplaceAll(part, quote, quote+quote) + quote
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// placeholder returns the n-th query parameter for the database type
+func placeholder(dbType string, n int) string {
+	switch dbType {
+	case "postgres", "postgresql", "pgx":
+		return fmt.Sprintf("$%d", n)
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,276 @@
+package rapidus
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		dbType, name, want string
+	}{
+		{"postgres", "users", `"users"`},
+		{"pgx", "public.users", `"public"."users"`},
+		{"postgres", `we"ird`, `"we""ird"`},
+		{"mysql", "users", "`users`"},
+		{"mariadb", "app.users", "`app`.`users`"},
+		{"mysql", "we`ird", "`we``ird`"},
+		{"sqlite", "users", `"users"`},
+		{"sqlite", `main.we"ird`, `"main"."we""ird"`},
+	}
+	for _, tt := range tests {
+		if got := quoteIdentifier(tt.dbType, tt.name); got != tt.want {
+			t.Errorf("%s %q: expected %s, got %s", tt.dbType, tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	tests := []struct {
+		dbType string
+		n      int
+		want   string
+	}{
+		{"postgres", 1, "$1"},
+		{"postgresql", 2, "$2"},
+		{"pgx", 2, "$2"},
+		{"mysql", 1, "?"},
+		{"mariadb", 2, "?"},
+		{"sqlite", 2, "?"},
+	}
+	for _, tt := range tests {
+		if got := placeholder(tt.dbType, tt.n); got != tt.want {
+			t.Errorf("%s %d: expected %s, got %s", tt.dbType, tt.n, tt.want, got)
+		}
+	}
+}
+
+func TestExistsQuery(t *testing.T) {
+	tests := []struct {
+		dbType   string
+		idColumn string
+		want     string
+		args     []interface{}
+	}{
+		{"postgres", "", `select 1 from "users" where "email" = $1 limit 1`, []interface{}{"a@example.com"}},
+		{"pgx", "id", `select 1 from "users" where "email" = $1 and "id" <> $2 limit 1`, []interface{}{"a@example.com", 7}},
+		{"mysql", "", "select 1 from `users` where `email` = ? limit 1", []interface{}{"a@example.com"}},
+		{"mariadb", "uid", "select 1 from `users` where `email` = ? and `uid` <> ? limit 1", []interface{}{"a@example.com", 7}},
+		{"sqlite", "id", `select 1 from "users" where "email" = ? and "id" <> ? limit 1`, []interface{}{"a@example.com", 7}},
+	}
+	for _, tt := range tests {
+		query, args := existsQuery(tt.dbType, "users", "email", "a@example.com", tt.idColumn, 7)
+		if query != tt.want || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: expected %s %v, got %s %v", tt.dbType, tt.want, tt.args, query, args)
+		}
+	}
+}
+
+func TestRapidus_Unique(t *testing.T) {
+	tests := []struct {
+		name     string
+		ignoreID []interface{}
+		found    bool
+		query    string
+		args     []driver.Value
+		err      string
+	}{
+		{"free", nil, false, `select 1 from "users" where "email" = $1 limit 1`, []driver.Value{"a@example.com"}, ""},
+		{"taken", nil, true, `select 1 from "users" where "email" = $1 limit 1`, []driver.Value{"a@example.com"}, "This value is already taken"},
+		{"own row ignored", []interface{}{int64(7)}, false, `select 1 from "users" where "email" = $1 and "id" <> $2 limit 1`, []driver.Value{"a@example.com", int64(7)}, ""},
+		{"taken by another row", []interface{}{int64(7)}, true, `select 1 from "users" where "email" = $1 and "id" <> $2 limit 1`, []driver.Value{"a@example.com", int64(7)}, "This value is already taken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &rulesDB{found: tt.found}
+			app := rulesApp("postgres", db)
+
+			v := app.Validator(nil)
+			v.Errors = map[string]string{}
+			if err := app.Unique(context.Background(), v, "email", "users", "email", "a@example.com", tt.ignoreID...); err != nil {
+				t.Fatal(err)
+			}
+
+			if v.Errors["email"] != tt.err {
+				t.Errorf("expected %q, got %q", tt.err, v.Errors["email"])
+			}
+			if len(db.queries) != 1 || db.queries[0].query != tt.query || !reflect.DeepEqual(db.queries[0].args, tt.args) {
+				t.Errorf("expected %s %v, got %+v", tt.query, tt.args, db.queries)
+			}
+		})
+	}
+}
+
+func TestRapidus_Exists(t *testing.T) {
+	for _, found := range []bool{true, false} {
+		db := &rulesDB{found: found}
+		app := rulesApp("mysql", db)
+
+		v := app.Validator(nil)
+		v.Errors = map[string]string{}
+		if err := app.Exists(context.Background(), v, "role_id", "roles", "id", int64(3)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, invalid := v.Errors["role_id"]; invalid == found {
+			t.Errorf("found %v: got errors %v", found, v.Errors)
+		}
+		if len(db.queries) != 1 || db.queries[0].query != "select 1 from `roles` where `id` = ? limit 1" {
+			t.Errorf("unexpected queries %+v", db.queries)
+		}
+	}
+}
+
+func TestRapidus_Unique_skipped(t *testing.T) {
+	db := &rulesDB{found: true}
+	app := rulesApp("postgres", db)
+
+	v := app.Validator(nil)
+	v.Errors = map[string]string{"email": "Invalid email"}
+	if err := app.Unique(context.Background(), v, "email", "users", "email", "not an email"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Exists(context.Background(), v, "email", "users", "email", "not an email"); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Errors["email"] != "Invalid email" || len(db.queries) != 0 {
+		t.Errorf("expected a field with an error not to be checked, got %v %+v", v.Errors, db.queries)
+	}
+}
+
+func TestRapidus_Unique_error(t *testing.T) {
+	db := &rulesDB{err: errors.New("connection refused")}
+	app := rulesApp("postgres", db)
+
+	v := app.Validator(nil)
+	v.Errors = map[string]string{}
+	if err := app.Unique(context.Background(), v, "email", "users", "email", "a@example.com"); err == nil {
+		t.Error("expected the database error")
+	}
+
+	app.DB.Pool = nil
+	if err := app.Exists(context.Background(), v, "email", "users", "email", "a@example.com"); err == nil {
+		t.Error("expected an error without a database connection")
+	}
+}
+
+func TestRapidus_uniqueRule(t *testing.T) {
+	type user struct {
+		ID    int64
+		Email string `validate:"unique:users,email,ID"`
+	}
+	type account struct {
+		UID   *int64
+		Email string `validate:"unique:accounts,email,UID,uid"`
+	}
+	type member struct {
+		RoleID int64 `validate:"exists:roles,id"`
+	}
+
+	uid := int64(9)
+	tests := []struct {
+		name  string
+		value interface{}
+		found bool
+		query string
+		args  []driver.Value
+		err   string
+	}{
+		{"new record", user{Email: "a@example.com"}, false, `select 1 from "users" where "email" = $1 limit 1`, []driver.Value{"a@example.com"}, ""},
+		{"new record taken", user{Email: "a@example.com"}, true, `select 1 from "users" where "email" = $1 limit 1`, []driver.Value{"a@example.com"}, "This value is already taken"},
+		{"existing record", user{ID: 7, Email: "a@example.com"}, false, `select 1 from "users" where "email" = $1 and "id" <> $2 limit 1`, []driver.Value{"a@example.com", int64(7)}, ""},
+		{"id column", account{UID: &uid, Email: "a@example.com"}, true, `select 1 from "accounts" where "email" = $1 and "uid" <> $2 limit 1`, []driver.Value{"a@example.com", int64(9)}, "This value is already taken"},
+		{"nil id", account{Email: "a@example.com"}, false, `select 1 from "accounts" where "email" = $1 limit 1`, []driver.Value{"a@example.com"}, ""},
+		{"exists", member{RoleID: 3}, true, `select 1 from "roles" where "id" = $1 limit 1`, []driver.Value{int64(3)}, ""},
+		{"does not exist", member{RoleID: 3}, false, `select 1 from "roles" where "id" = $1 limit 1`, []driver.Value{int64(3)}, "The selected value is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &rulesDB{found: tt.found}
+			app := rulesApp("postgres", db)
+
+			errs := app.createRules().Validate(context.Background(), tt.value)
+			var got string
+			for _, msg := range errs {
+				got = msg
+			}
+
+			if got != tt.err {
+				t.Errorf("expected %q, got %v", tt.err, errs)
+			}
+			if len(db.queries) != 1 || db.queries[0].query != tt.query || !reflect.DeepEqual(db.queries[0].args, tt.args) {
+				t.Errorf("expected %s %v, got %+v", tt.query, tt.args, db.queries)
+			}
+		})
+	}
+}
+
+func rulesApp(dbType string, db *rulesDB) *Rapidus {
+	app := newTestApp()
+	app.DB = Database{Type: dbType, Pool: sql.OpenDB(db)}
+	return app
+}
+
+// rulesDB is a database that records the queries it is sent, and finds a row for each of them
+// when found is set
+type rulesDB struct {
+	found   bool
+	err     error
+	queries []rulesQuery
+}
+
+type rulesQuery struct {
+	query string
+	args  []driver.Value
+}
+
+func (db *rulesDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *rulesDB) Driver() driver.Driver                        { return nil }
+func (db *rulesDB) Prepare(query string) (driver.Stmt, error) {
+	return &rulesStmt{db, query}, nil
+}
+func (db *rulesDB) Close() error              { return nil }
+func (db *rulesDB) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type rulesStmt struct {
+	db    *rulesDB
+	query string
+}
+
+func (s *rulesStmt) Close() error  { return nil }
+func (s *rulesStmt) NumInput() int { return -1 }
+
+func (s *rulesStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+func (s *rulesStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.db.err != nil {
+		return nil, s.db.err
+	}
+
+	s.db.queries = append(s.db.queries, rulesQuery{s.query, args})
+	return &rulesRows{found: s.db.found}, nil
+}
+
+type rulesRows struct {
+	found bool
+}
+
+func (r *rulesRows) Columns() []string { return []string{"1"} }
+func (r *rulesRows) Close() error      { return nil }
+
+func (r *rulesRows) Next(dest []driver.Value) error {
+	if !r.found {
+		return io.EOF
+	}
+	dest[0] = int64(1)
+	r.found = false
+	return nil
+}
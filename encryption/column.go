@@ -0,0 +1,104 @@
+package encryption
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	defaultMu         sync.RWMutex
+	defaultEncryption *Encryption
+)
+
+// SetDefault sets the Encryption used by the encrypted column types. Rapidus sets it to the
+// Encryption created from KEY
+func SetDefault(e *Encryption) {
+	defaultMu.Lock()
+	defaultEncryption = e
+	defaultMu.Unlock()
+}
+
+// Default returns the Encryption used by the encrypted column types
+func Default() (*Encryption, error) {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+
+	if defaultEncryption == nil {
+		return nil, errors.New("encryption: no default encryption set, is KEY set in .env?")
+	}
+	return defaultEncryption, nil
+}
+
+// String is a string that is stored encrypted in the database, e.g. as a field of a model:
+//
+//	type User struct {
+//		ID  int               `db:"id"`
+//		SSN encryption.String `db:"ssn"`
+//	}
+type String string
+
+// Value encrypts the string for the database
+func (s String) Value() (driver.Value, error) {
+	e, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return e.EncryptString(string(s))
+}
+
+// Scan decrypts a value read from the database
+func (s *String) Scan(src interface{}) error {
+	data, err := scanDecrypt(src)
+	if err != nil {
+		return err
+	}
+
+	*s = String(data)
+	return nil
+}
+
+// Bytes is a byte slice that is stored encrypted in the database
+type Bytes []byte
+
+// Value encrypts the bytes for the database
+func (b Bytes) Value() (driver.Value, error) {
+	e, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return e.Encrypt(b)
+}
+
+// Scan decrypts a value read from the database
+func (b *Bytes) Scan(src interface{}) error {
+	data, err := scanDecrypt(src)
+	if err != nil {
+		return err
+	}
+
+	*b = data
+	return nil
+}
+
+// scanDecrypt decrypts a ciphertext read from the database. NULL is read as an empty value
+func scanDecrypt(src interface{}) ([]byte, error) {
+	var ciphertext string
+	switch v := src.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return nil, fmt.Errorf("encryption: can't scan %T into an encrypted column", src)
+	}
+
+	e, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return e.Decrypt(ciphertext)
+}
@@ -0,0 +1,35 @@
+package encryption
+
+import (
+	"net/http"
+)
+
+// SetCookie encrypts the value of a cookie and sets it. The cookie name is authenticated with
+// the value, so an encrypted value can't be moved to a cookie with another name
+func (e *Encryption) SetCookie(w http.ResponseWriter, cookie *http.Cookie) error {
+	value, err := e.seal([]byte(cookie.Value), []byte(cookie.Name))
+	if err != nil {
+		return err
+	}
+
+	encrypted := *cookie
+	encrypted.Value = value
+	http.SetCookie(w, &encrypted)
+
+	return nil
+}
+
+// ReadCookie returns the decrypted value of a cookie set with SetCookie
+func (e *Encryption) ReadCookie(r *http.Request, name string) (string, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := e.open(cookie.Value, []byte(name))
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
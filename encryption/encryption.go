@@ -0,0 +1,168 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// version prefixes every ciphertext, so the format can change without breaking stored values
+const version = "v1"
+
+var (
+	ErrInvalidKey        = errors.New("encryption: key must be 32 bytes")
+	ErrInvalidCiphertext = errors.New("encryption: invalid ciphertext")
+	ErrUnknownKey        = errors.New("encryption: ciphertext was encrypted with an unknown key")
+	ErrNoKey             = errors.New("encryption: no key, is KEY set in .env?")
+)
+
+// Encryption encrypts with AES-256-GCM. Ciphertexts are URL safe strings of the form
+// v1.<key id>.<nonce and sealed data>, where the key id identifies the key that encrypted
+// them, so values encrypted with a previous key can still be decrypted during a key rotation
+type Encryption struct {
+	keys []key
+}
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// New creates an Encryption that encrypts with key and decrypts with key or any of the previous
+// keys. Keys must be 32 bytes, such as the KEY created by rapidus make key
+func New(current []byte, previous ...[]byte) (*Encryption, error) {
+	e := &Encryption{}
+	for _, k := range append([][]byte{current}, previous...) {
+		if len(k) != 32 {
+			return nil, ErrInvalidKey
+		}
+
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		e.keys = append(e.keys, key{id: keyID(k), aead: aead})
+	}
+
+	return e, nil
+}
+
+// Encrypt encrypts data with the current key
+func (e *Encryption) Encrypt(data []byte) (string, error) {
+	return e.seal(data, nil)
+}
+
+// Decrypt decrypts a ciphertext created by Encrypt, with the key it was encrypted with
+func (e *Encryption) Decrypt(ciphertext string) ([]byte, error) {
+	return e.open(ciphertext, nil)
+}
+
+// EncryptString encrypts a string with the current key
+func (e *Encryption) EncryptString(s string) (string, error) {
+	return e.Encrypt([]byte(s))
+}
+
+// DecryptString decrypts a ciphertext created by EncryptString
+func (e *Encryption) DecryptString(ciphertext string) (string, error) {
+	data, err := e.Decrypt(ciphertext)
+	return string(data), err
+}
+
+// IsCurrent reports whether a ciphertext was encrypted with the current key, i.e. whether it
+// doesn't need to be encrypted again after a key rotation
+func (e *Encryption) IsCurrent(ciphertext string) bool {
+	if e == nil {
+		return false
+	}
+	id, _, err := parse(ciphertext)
+	return err == nil && id == e.keys[0].id
+}
+
+// Reencrypt decrypts a ciphertext with the key it was encrypted with, and encrypts it with the
+// current key
+func (e *Encryption) Reencrypt(ciphertext string) (string, error) {
+	data, err := e.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return e.Encrypt(data)
+}
+
+// seal encrypts data with the current key. The additional data is authenticated but not
+// encrypted, and must be given again to open the ciphertext. A nil Encryption, as the app has
+// without KEY, returns ErrNoKey
+func (e *Encryption) seal(data, additional []byte) (string, error) {
+	if e == nil {
+		return "", ErrNoKey
+	}
+	k := e.keys[0]
+
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(data)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := k.aead.Seal(nonce, nonce, data, additional)
+	return fmt.Sprintf("%s.%s.%s", version, k.id, base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+func (e *Encryption) open(ciphertext string, additional []byte) ([]byte, error) {
+	if e == nil {
+		return nil, ErrNoKey
+	}
+	id, sealed, err := parse(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range e.keys {
+		if k.id != id {
+			continue
+		}
+
+		if len(sealed) < k.aead.NonceSize() {
+			return nil, ErrInvalidCiphertext
+		}
+
+		nonce, data := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+		plain, err := k.aead.Open(nil, nonce, data, additional)
+		if err != nil {
+			return nil, ErrInvalidCiphertext
+		}
+		return plain, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// parse splits a ciphertext into the id of its key and the sealed data
+func parse(ciphertext string) (string, []byte, error) {
+	parts := strings.Split(ciphertext, ".")
+	if len(parts) != 3 || parts[0] != version {
+		return "", nil, ErrInvalidCiphertext
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, ErrInvalidCiphertext
+	}
+
+	return parts[1], sealed, nil
+}
+
+// keyID identifies a key without revealing it
+func keyID(k []byte) string {
+	sum := sha256.Sum256(append([]byte("rapidus key id:"), k...))
+	return base64.RawURLEncoding.EncodeToString(sum[:6])
+}
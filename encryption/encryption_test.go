@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var currentKey = []byte("abcdefghijklmnopqrstuvwxyz012345")
+var previousKey = []byte("543210zyxwvutsrqponmlkjihgfedcba")
+
+func TestEncryption_EncryptDecrypt(t *testing.T) {
+	e, err := New(currentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := e.EncryptString("my secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(ciphertext, "v1.") || strings.ContainsAny(ciphertext, "+/=") {
+		t.Error("ciphertext is not versioned or not URL safe:", ciphertext)
+	}
+
+	again, _ := e.EncryptString("my secret")
+	if again == ciphertext {
+		t.Error("encrypting twice gave the same ciphertext")
+	}
+
+	plain, err := e.DecryptString(ciphertext)
+	if err != nil || plain != "my secret" {
+		t.Errorf("expected my secret but got %q (%v)", plain, err)
+	}
+
+	var tests = []struct {
+		name       string
+		ciphertext string
+	}{
+		{"tampered", ciphertext[:len(ciphertext)-2] + "AA"},
+		{"wrong version", "v2" + ciphertext[2:]},
+		{"not encoded", "v1.abc.!!!"},
+		{"empty", ""},
+	}
+
+	for _, test := range tests {
+		if _, err := e.Decrypt(test.ciphertext); err == nil {
+			t.Errorf("%s: no error decrypting", test.name)
+		}
+	}
+}
+
+func TestEncryption_Rotation(t *testing.T) {
+	old, _ := New(previousKey)
+	ciphertext, _ := old.Encrypt([]byte("rotate me"))
+
+	current, _ := New(currentKey)
+	if _, err := current.Decrypt(ciphertext); err != ErrUnknownKey {
+		t.Error("expected ErrUnknownKey, got", err)
+	}
+
+	rotating, _ := New(currentKey, previousKey)
+	plain, err := rotating.Decrypt(ciphertext)
+	if err != nil || !bytes.Equal(plain, []byte("rotate me")) {
+		t.Errorf("expected rotate me but got %q (%v)", plain, err)
+	}
+
+	if rotating.IsCurrent(ciphertext) {
+		t.Error("ciphertext of a previous key reported as current")
+	}
+
+	reencrypted, _ := rotating.Reencrypt(ciphertext)
+	if !rotating.IsCurrent(reencrypted) {
+		t.Error("re-encrypted ciphertext is not current")
+	}
+
+	if _, err := current.Decrypt(reencrypted); err != nil {
+		t.Error("re-encrypted ciphertext can't be decrypted with the current key:", err)
+	}
+}
+
+func TestNew_InvalidKey(t *testing.T) {
+	if _, err := New([]byte("too short")); err != ErrInvalidKey {
+		t.Error("expected ErrInvalidKey, got", err)
+	}
+
+	if _, err := New(currentKey, []byte("too short")); err != ErrInvalidKey {
+		t.Error("expected ErrInvalidKey for a previous key, got", err)
+	}
+}
+
+func TestEncryption_Nil(t *testing.T) {
+	var e *Encryption
+
+	if _, err := e.EncryptString("secret"); err != ErrNoKey {
+		t.Error("expected ErrNoKey when encrypting, got", err)
+	}
+	if _, err := e.DecryptString("v1.id.data"); err != ErrNoKey {
+		t.Error("expected ErrNoKey when decrypting, got", err)
+	}
+	if err := e.SetCookie(httptest.NewRecorder(), &http.Cookie{Name: "c", Value: "v"}); err != ErrNoKey {
+		t.Error("expected ErrNoKey when setting a cookie, got", err)
+	}
+	if e.IsCurrent("v1.id.data") {
+		t.Error("ciphertext is current without a key")
+	}
+}
+
+func TestEncryption_Cookie(t *testing.T) {
+	e, _ := New(currentKey)
+
+	rr := httptest.NewRecorder()
+	err := e.SetCookie(rr, &http.Cookie{Name: "prefs", Value: "dark", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookie := rr.Result().Cookies()[0]
+	if cookie.Value == "dark" || cookie.Path != "/" {
+		t.Errorf("cookie not encrypted or attributes lost: %+v", cookie)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+
+	value, err := e.ReadCookie(req, "prefs")
+	if err != nil || value != "dark" {
+		t.Errorf("expected dark but got %q (%v)", value, err)
+	}
+
+	// the same value in a cookie with another name must be rejected
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session", Value: cookie.Value})
+	if _, err := e.ReadCookie(req, "session"); err == nil {
+		t.Error("no error reading a value moved to another cookie")
+	}
+}
+
+func TestColumnTypes(t *testing.T) {
+	e, _ := New(currentKey)
+	SetDefault(e)
+	defer SetDefault(nil)
+
+	value, err := String("123-45-6789").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s String
+	if err := s.Scan([]byte(value.(string))); err != nil || s != "123-45-6789" {
+		t.Errorf("expected 123-45-6789 but got %q (%v)", s, err)
+	}
+
+	value, _ = Bytes("raw").Value()
+	var b Bytes
+	if err := b.Scan(value); err != nil || string(b) != "raw" {
+		t.Errorf("expected raw but got %q (%v)", b, err)
+	}
+
+	if err := s.Scan(nil); err != nil || s != "" {
+		t.Error("NULL should scan as an empty string")
+	}
+
+	if err := s.Scan(42); err == nil {
+		t.Error("no error scanning an int")
+	}
+}
@@ -0,0 +1,231 @@
+package rapidus
+
+import (
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5/middleware"
+	"html/template"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// SetErrorPage registers the templ component ErrorPage renders for a status code.
+// Use status 0 to register a page for every status that does not have its own page
+func (r *Rapidus) SetErrorPage(status int, page templ.Component) {
+	if r.errorPages == nil {
+		r.errorPages = make(map[int]templ.Component)
+	}
+	r.errorPages[status] = page
+}
+
+// ErrorPage writes an error response for the given status. Clients asking for JSON or XML receive
+// problem details, everyone else the registered error page, or plain text if there is none
+func (r *Rapidus) ErrorPage(w http.ResponseWriter, req *http.Request, status int) {
+	r.errorPage(w, req, status, "")
+}
+
+func (r *Rapidus) errorPage(w http.ResponseWriter, req *http.Request, status int, detail string) {
+	if wantsProblem(req) {
+		r.ErrorProblem(w, req, status, detail)
+		return
+	}
+
+	page, ok := r.errorPages[status]
+	if !ok {
+		page, ok = r.errorPages[0]
+	}
+
+	if !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	err := r.Render.ErrorPage(w, req, status, page)
+	if err != nil && r.ErrorLog != nil {
+		r.ErrorLog.Println(err)
+	}
+}
+
+// wantsProblem reports whether the client prefers a JSON or XML response over html
+func wantsProblem(req *http.Request) bool {
+	return negotiate(req, "text/html", "application/json", "application/xml") != "text/html"
+}
+
+// Recoverer recovers from panics, logs the panic with the request id and shows an error page.
+// In debug mode the page shows the stack trace, request details and the source around each frame
+func (r *Rapidus) Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				if rvr == http.ErrAbortHandler {
+					// the response to the client is aborted, don't recover or log it
+					panic(rvr)
+				}
+
+				r.ErrorLog.Printf("panic: %v\nrequest id: %s %s %s\n%s",
+					rvr, middleware.GetReqID(req.Context()), req.Method, req.URL.RequestURI(), debug.Stack())
+
+				if req.Header.Get("Connection") == "Upgrade" {
+					return
+				}
+
+				if !r.Debug {
+					r.errorPage(w, req, http.StatusInternalServerError, "")
+					return
+				}
+
+				if wantsProblem(req) {
+					r.errorPage(w, req, http.StatusInternalServerError, fmt.Sprint(rvr))
+					return
+				}
+
+				r.debugErrorPage(w, req, rvr)
+			}
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+type debugFrame struct {
+	Function string
+	File     string
+	Line     int
+	Source   []sourceLine
+}
+
+type sourceLine struct {
+	Number  int
+	Code    string
+	Current bool
+}
+
+func (r *Rapidus) debugErrorPage(w http.ResponseWriter, req *http.Request, rvr interface{}) {
+	headers := req.Header.Clone()
+	for _, h := range []string{"Authorization", "Cookie"} {
+		if headers.Get(h) != "" {
+			headers.Set(h, "[redacted]")
+		}
+	}
+
+	data := struct {
+		Panic     string
+		Method    string
+		URL       string
+		RemoteIP  string
+		RequestID string
+		Nonce     string
+		Headers   http.Header
+		Frames    []debugFrame
+	}{
+		Panic:     fmt.Sprint(rvr),
+		Method:    req.Method,
+		URL:       req.URL.String(),
+		RemoteIP:  req.RemoteAddr,
+		RequestID: middleware.GetReqID(req.Context()),
+		Nonce:     templ.GetNonce(req.Context()),
+		Headers:   headers,
+		Frames:    panicFrames(),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	err := debugPage.Execute(w, data)
+	if err != nil {
+		r.ErrorLog.Println(err)
+	}
+}
+
+// panicFrames returns the frames of the panicking goroutine, with the source around each frame.
+// It must be called from the deferred function that recovered
+func panicFrames() []debugFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	// skip the recovering frames, up to and including the call to panic
+	var result []debugFrame
+	for {
+		frame, more := frames.Next()
+		if frame.Function == "runtime.gopanic" {
+			result = nil
+		} else if !strings.HasPrefix(frame.Function, "runtime.") {
+			result = append(result, debugFrame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+				Source:   sourceAround(frame.File, frame.Line, 5),
+			})
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return result
+}
+
+// sourceAround reads the lines surrounding line from file
+func sourceAround(file string, line, context int) []sourceLine {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	start := max(line-context, 1)
+	end := min(line+context, len(lines))
+
+	var result []sourceLine
+	for i := start; i <= end; i++ {
+		result = append(result, sourceLine{
+			Number:  i,
+			Code:    lines[i-1],
+			Current: i == line,
+		})
+	}
+
+	return result
+}
+
+var debugPage = template.Must(template.New("debug").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>panic: {{.Panic}}</title>
+<style nonce="{{.Nonce}}">
+body { font-family: sans-serif; margin: 2rem; color: #222; }
+h1 { color: #b00020; font-size: 1.4rem; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+td { padding: .2rem 1rem .2rem 0; vertical-align: top; font-family: monospace; }
+.frame { margin-bottom: 1.5rem; }
+.file { color: #555; font-family: monospace; }
+pre { background: #f6f6f6; padding: .5rem; overflow-x: auto; }
+.current { background: #ffe0e0; display: block; }
+</style>
+</head>
+<body>
+<h1>panic: {{.Panic}}</h1>
+<h2>Request</h2>
+<table>
+<tr><td>Method</td><td>{{.Method}}</td></tr>
+<tr><td>URL</td><td>{{.URL}}</td></tr>
+<tr><td>Remote address</td><td>{{.RemoteIP}}</td></tr>
+<tr><td>Request ID</td><td>{{.RequestID}}</td></tr>
+{{range $name, $values := .Headers}}<tr><td>{{$name}}</td><td>{{range $values}}{{.}} {{end}}</td></tr>
+{{end}}</table>
+<h2>Stack trace</h2>
+{{range .Frames}}<div class="frame">
+<div><strong>{{.Function}}</strong></div>
+<div class="file">{{.File}}:{{.Line}}</div>
+{{if .Source}}<pre>{{range .Source}}<span{{if .Current}} class="current"{{end}}>{{printf "%4d" .Number}}  {{.Code}}</span>
+{{end}}</pre>{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,131 @@
+package rapidus
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestApp() *Rapidus {
+	return &Rapidus{
+		ErrorLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+	}
+}
+
+func TestRapidus_ErrorStatus(t *testing.T) {
+	app := newTestApp()
+	app.SetErrorPage(0, templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, "custom page")
+		return err
+	}))
+
+	tests := []struct {
+		fn     func(http.ResponseWriter)
+		status int
+	}{
+		{app.Error404, http.StatusNotFound},
+		{app.Error500, http.StatusInternalServerError},
+		{app.ErrorUnauthorized, http.StatusUnauthorized},
+		{app.ErrorForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.fn(w)
+		if w.Code != tt.status || strings.TrimSpace(w.Body.String()) != http.StatusText(tt.status) {
+			t.Errorf("expected plain text %d, got %d %q", tt.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRapidus_ErrorStatusFor(t *testing.T) {
+	app := newTestApp()
+
+	w := httptest.NewRecorder()
+	app.Error404For(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != "Not Found" {
+		t.Errorf("expected plain text 404 without a page, got %d %q", w.Code, w.Body.String())
+	}
+
+	app.SetErrorPage(0, templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, "custom page")
+		return err
+	}))
+
+	for _, fn := range []func(http.ResponseWriter, *http.Request){app.Error404For, app.Error500For, app.ErrorUnauthorizedFor, app.ErrorForbiddenFor} {
+		w = httptest.NewRecorder()
+		fn(w, httptest.NewRequest("GET", "/", nil))
+		if w.Body.String() != "custom page" {
+			t.Errorf("expected the registered page for %d, got %q", w.Code, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "application/json")
+	app.ErrorStatusFor(w, req, http.StatusBadRequest)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a problem response, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRapidus_Recoverer(t *testing.T) {
+	app := newTestApp()
+	handler := app.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something broke")
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", "/items?id=1", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Cookie", "session=secret-session")
+		req.Header.Set("X-Custom", "visible")
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "something broke") {
+		t.Error("panic shown outside debug mode:", w.Body.String())
+	}
+
+	app.Debug = true
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	body := w.Body.String()
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+	// the page shows the source of the test, so the headers are checked by their rows
+	for _, expected := range []string{"panic: something broke", "/items?id=1", "<td>X-Custom</td><td>visible </td>",
+		"<td>Authorization</td><td>[redacted] </td>", "<td>Cookie</td><td>[redacted] </td>", "TestRapidus_Recoverer"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in the debug page", expected)
+		}
+	}
+
+	req := newRequest()
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "something broke") || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a problem response with the panic, got %s", w.Body.String())
+	}
+
+	defer func() {
+		if rvr := recover(); rvr != http.ErrAbortHandler {
+			t.Errorf("expected ErrAbortHandler to be re-panicked, got %v", rvr)
+		}
+	}()
+	app.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).ServeHTTP(httptest.NewRecorder(), newRequest())
+}
@@ -1,25 +1,14 @@
 package rapidus
 
 import (
-	"crypto/rand"
+	"github.com/fouched/rapidus/token"
 	"os"
 )
 
-const (
-	randomString = "abcdefghijklmnopqrstuvwzyzABCDEFGHIKLMNOPQRSTUVWXYZ_+1234567890"
-)
-
-// RandomString generates a random string of length n
+// RandomString generates a random string of length n, of letters, digits, _ and +.
+// See the token package for other alphabets, UUIDs and ULIDs
 func (r *Rapidus) RandomString(n int) string {
-
-	s, t := make([]rune, n), []rune(randomString)
-
-	for i := range s {
-		p, _ := rand.Prime(rand.Reader, len(t))
-		x, y := p.Uint64(), uint64(len(t))
-		s[i] = t[x%y]
-	}
-	return string(s)
+	return token.String(n, token.AlphanumericSymbols)
 }
 
 func (r *Rapidus) CreateDirIfNotExist(path string) error {
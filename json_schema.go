@@ -0,0 +1,108 @@
+package rapidus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/fouched/rapidus/schema"
+	"io"
+	"net/http"
+	"os"
+)
+
+// SchemaError is returned by ReadJSON when the body does not match the JSON schema
+type SchemaError struct {
+	Errors map[string]string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("body does not match the JSON schema: %d invalid field(s)", len(e.Errors))
+}
+
+// loadSchemas compiles every schema in the application's schemas folder. Schemas embedded
+// in the binary can be added with r.Schemas.LoadFS
+func (r *Rapidus) loadSchemas() (*schema.Registry, error) {
+	registry := schema.New()
+
+	dir := r.RootPath + "/schemas"
+	if _, err := os.Stat(dir); err != nil {
+		return registry, nil
+	}
+
+	err := registry.LoadFS(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// ValidateJSON is middleware that validates the request body against the named JSON schema
+// before the handler runs. Invalid bodies are rejected with a problem response listing the
+// errors per field; valid bodies are passed on to the handler unchanged. A schema that does not
+// exist is logged, and requests get a 500 problem response
+func (r *Rapidus) ValidateJSON(name string) func(http.Handler) http.Handler {
+	if !r.Schemas.Has(name) {
+		r.ErrorLog.Printf("no JSON schema named %s", name)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			err := r.validateBody(w, req, name)
+			if err != nil {
+				r.schemaProblem(w, req, err)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// validateBody validates the request body against a schema, and replaces the body so it
+// can be read again
+func (r *Rapidus) validateBody(w http.ResponseWriter, req *http.Request, name string) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxJSONBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return &jsonError{http.StatusRequestEntityTooLarge, fmt.Sprintf("body must not be larger than %d bytes", maxBytesError.Limit)}
+		}
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &jsonError{http.StatusBadRequest, "body must not be empty"}
+	}
+
+	errs, err := r.Schemas.Validate(name, body)
+	if errors.Is(err, schema.ErrInvalidJSON) {
+		return &jsonError{http.StatusBadRequest, err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return &SchemaError{Errors: errs}
+	}
+
+	return nil
+}
+
+// schemaProblem writes the problem response for an error returned by validateBody
+func (r *Rapidus) schemaProblem(w http.ResponseWriter, req *http.Request, err error) {
+	var schemaErr *SchemaError
+	var jsonErr *jsonError
+
+	switch {
+	case errors.As(err, &schemaErr):
+		r.ValidationProblem(w, req, &Validation{Errors: schemaErr.Errors})
+	case errors.As(err, &jsonErr):
+		r.ErrorProblem(w, req, jsonErr.status, jsonErr.detail)
+	default:
+		r.ErrorLog.Println(err)
+		r.ErrorProblem(w, req, http.StatusInternalServerError, "")
+	}
+}
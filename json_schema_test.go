@@ -0,0 +1,43 @@
+package rapidus
+
+import (
+	"github.com/fouched/rapidus/schema"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRapidus_ValidateJSON_UnknownSchema(t *testing.T) {
+	app := newTestApp()
+	app.Schemas = schema.New()
+
+	handler := app.ValidateJSON("missing")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called without a schema")
+	}))
+
+	req := httptest.NewRequest("POST", "/api/items", strings.NewReader(`{"name":"x"}`))
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a 500 problem response, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestRapidus_loadSchemas(t *testing.T) {
+	app := newTestApp()
+	app.RootPath = t.TempDir()
+
+	if _, err := app.loadSchemas(); err != nil {
+		t.Error("error without a schemas folder:", err)
+	}
+
+	_ = os.Mkdir(app.RootPath+"/schemas", 0755)
+	_ = os.WriteFile(app.RootPath+"/schemas/broken.json", []byte(`{"type": `), 0644)
+	if _, err := app.loadSchemas(); err == nil {
+		t.Error("no error for an invalid schema")
+	}
+}
@@ -0,0 +1,198 @@
+package rapidus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fouched/rapidus/cache"
+	"github.com/fouched/rapidus/encryption"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"strings"
+	"time"
+)
+
+// PreviousKey is a KEY that was rotated out. Signed URLs, encrypted cookies and encrypted values
+// are still accepted with it until its cutoff. After the cutoff, rapidus key rotate keeps it in
+// PREVIOUS_KEYS until --reencrypt has encrypted the stored values again with the current key
+type PreviousKey struct {
+	Key   string
+	Until time.Time
+}
+
+// ParsePreviousKeys parses PREVIOUS_KEYS, a comma separated list of key@cutoff, where the
+// cutoff is an RFC 3339 time
+func ParsePreviousKeys(s string) ([]PreviousKey, error) {
+	var keys []PreviousKey
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, "@")
+		if i < 0 {
+			return nil, errors.New("previous key without a cutoff, expected key@cutoff")
+		}
+
+		until, err := time.Parse(time.RFC3339, entry[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("previous key with an invalid cutoff: %w", err)
+		}
+
+		keys = append(keys, PreviousKey{Key: entry[:i], Until: until})
+	}
+
+	return keys, nil
+}
+
+// FormatPreviousKeys formats previous keys for PREVIOUS_KEYS
+func FormatPreviousKeys(keys []PreviousKey) string {
+	entries := make([]string, len(keys))
+	for i, k := range keys {
+		entries[i] = k.Key + "@" + k.Until.UTC().Format(time.RFC3339)
+	}
+	return strings.Join(entries, ",")
+}
+
+// previousKeys returns the keys in PREVIOUS_KEYS whose cutoff has not passed
+func (r *Rapidus) previousKeys() [][]byte {
+	keys, err := ParsePreviousKeys(os.Getenv("PREVIOUS_KEYS"))
+	if err != nil {
+		r.ErrorLog.Println("PREVIOUS_KEYS:", err)
+		return nil
+	}
+
+	var active [][]byte
+	for _, k := range keys {
+		if time.Now().Before(k.Until) {
+			active = append(active, []byte(k.Key))
+		}
+	}
+	return active
+}
+
+// ReencryptColumns encrypts the values of encrypted database columns again with the current key
+// of e, if they were encrypted with a previous key. Columns are given as table.column, and rows
+// are identified by their id column, or as table.column:key for a table with another primary key.
+// It returns the number of values encrypted again
+func (r *Rapidus) ReencryptColumns(ctx context.Context, e *encryption.Encryption, columns []string) (int, error) {
+	if r.DB.Pool == nil {
+		return 0, errors.New("re-encrypting columns needs a database connection")
+	}
+
+	updated := 0
+	for _, c := range columns {
+		tableName, columnName, keyName, err := parseEncryptedColumn(c)
+		if err != nil {
+			return updated, err
+		}
+		table := quoteIdentifier(r.DB.Type, tableName)
+		column := quoteIdentifier(r.DB.Type, columnName)
+		id := quoteIdentifier(r.DB.Type, keyName)
+
+		rows, err := r.DB.Pool.QueryContext(ctx, fmt.Sprintf("select %s, %s from %s where %s is not null", id, column, table, column))
+		if err != nil {
+			return updated, err
+		}
+
+		// values are collected first, as some drivers can't update while rows are open
+		reencrypted := make(map[interface{}]struct{ old, new string })
+		for rows.Next() {
+			var rowID interface{}
+			var value string
+			if err := rows.Scan(&rowID, &value); err != nil {
+				rows.Close()
+				return updated, err
+			}
+
+			if v, ok := reencryptValue(e, value); ok {
+				reencrypted[rowID] = struct{ old, new string }{value, v.(string)}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, err
+		}
+
+		// the old value is part of the condition, so a value changed since it was read is kept
+		query := fmt.Sprintf("update %s set %s = %s where %s = %s and %s = %s", table, column, placeholder(r.DB.Type, 1),
+			id, placeholder(r.DB.Type, 2), column, placeholder(r.DB.Type, 3))
+		for rowID, value := range reencrypted {
+			result, err := r.DB.Pool.ExecContext(ctx, query, value.new, rowID, value.old)
+			if err != nil {
+				return updated, err
+			}
+			if n, err := result.RowsAffected(); err == nil {
+				updated += int(n)
+			}
+		}
+	}
+
+	return updated, nil
+}
+
+// parseEncryptedColumn splits an ENCRYPTED_COLUMNS entry, table.column or table.column:key, into
+// the table, the column and the key column that identifies the rows
+func parseEncryptedColumn(entry string) (table, column, key string, err error) {
+	name, key, found := strings.Cut(strings.TrimSpace(entry), ":")
+	if !found {
+		key = "id"
+	}
+
+	i := strings.LastIndex(name, ".")
+	if i <= 0 || i == len(name)-1 || key == "" {
+		return "", "", "", fmt.Errorf("encrypted column %s must be given as table.column or table.column:key", entry)
+	}
+
+	return name[:i], name[i+1:], key, nil
+}
+
+// ReencryptCache encrypts the string values in the badger or Redis cache again with the current
+// key of e, if they were encrypted with a previous key. Badger can only be opened by one process,
+// so the application must be stopped to re-encrypt a badger cache
+func (r *Rapidus) ReencryptCache(ctx context.Context, e *encryption.Encryption) (int, error) {
+	switch os.Getenv("CACHE") {
+	case "badger":
+		conn := r.createBadgerConn()
+		if conn == nil {
+			return 0, errors.New("could not open the badger cache, is the application still running?")
+		}
+		defer conn.Close()
+
+		c := cache.BadgerCache{Conn: conn}
+		return c.Update("", func(_ string, value interface{}) (interface{}, bool) {
+			return reencryptValue(e, value)
+		})
+
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     os.Getenv("REDIS_HOST"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		})
+		defer client.Close()
+
+		c := cache.RedisCache{Conn: client, Prefix: os.Getenv("REDIS_PREFIX")}
+		return c.Update("", func(_ string, value interface{}) (interface{}, bool) {
+			return reencryptValue(e, value)
+		})
+	}
+
+	return 0, nil
+}
+
+// reencryptValue returns value encrypted with the current key, if it is a ciphertext of a
+// previous key
+func reencryptValue(e *encryption.Encryption, value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok || e.IsCurrent(s) {
+		return nil, false
+	}
+
+	reencrypted, err := e.Reencrypt(s)
+	if err != nil {
+		// not a ciphertext
+		return nil, false
+	}
+	return reencrypted, true
+}
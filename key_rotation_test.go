@@ -0,0 +1,184 @@
+package rapidus
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/fouched/rapidus/encryption"
+	"github.com/fouched/rapidus/urlsigner"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldTestKey = []byte("543210zyxwvutsrqponmlkjihgfedcba")
+	newTestKey = []byte("abcdefghijklmnopqrstuvwxyz012345")
+)
+
+func TestRapidus_PreviousKeys(t *testing.T) {
+	now := time.Now()
+	t.Setenv("PREVIOUS_KEYS", FormatPreviousKeys([]PreviousKey{
+		{Key: "active", Until: now.Add(time.Hour)},
+		{Key: "expired", Until: now.Add(-time.Hour)},
+	}))
+
+	keys := newTestApp().previousKeys()
+	if len(keys) != 1 || string(keys[0]) != "active" {
+		t.Errorf("expected only the active key, got %q", keys)
+	}
+}
+
+func TestRapidus_PreviousKeys_expired(t *testing.T) {
+	activeKey := []byte("0123456789abcdefghijklmnopqrstuv")
+	now := time.Now()
+	t.Setenv("PREVIOUS_KEYS", FormatPreviousKeys([]PreviousKey{
+		{Key: string(activeKey), Until: now.Add(time.Hour)},
+		{Key: string(oldTestKey), Until: now.Add(-time.Hour)},
+	}))
+
+	app := newTestApp()
+	app.EncryptionKey = string(newTestKey)
+	e, err := app.createEncryption(app.previousKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { encryption.SetDefault(nil) })
+
+	active, _ := encryption.New(activeKey)
+	ciphertext, _ := active.EncryptString("value")
+	if got, err := e.DecryptString(ciphertext); err != nil || got != "value" {
+		t.Errorf("expected a value encrypted with an active key to decrypt, got %q %v", got, err)
+	}
+
+	expired, _ := encryption.New(oldTestKey)
+	ciphertext, _ = expired.EncryptString("value")
+	if _, err := e.DecryptString(ciphertext); err == nil {
+		t.Error("expected a value encrypted with an expired key to be rejected")
+	}
+
+	signed, err := urlsigner.New(activeKey).Sign("https://example.com/reset", urlsigner.Options{TTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.urlSigner().Verify(signed, ""); err != nil {
+		t.Errorf("expected a URL signed with an active key to verify, got %v", err)
+	}
+}
+
+func TestParseEncryptedColumn(t *testing.T) {
+	tests := []struct {
+		entry, table, column, key string
+	}{
+		{"users.email", "users", "email", "id"},
+		{" users.email ", "users", "email", "id"},
+		{"public.users.email:user_id", "public.users", "email", "user_id"},
+	}
+	for _, tt := range tests {
+		table, column, key, err := parseEncryptedColumn(tt.entry)
+		if err != nil || table != tt.table || column != tt.column || key != tt.key {
+			t.Errorf("%q: got %q %q %q %v", tt.entry, table, column, key, err)
+		}
+	}
+
+	for _, entry := range []string{"email", ".email", "users.", "users.email:"} {
+		if _, _, _, err := parseEncryptedColumn(entry); err == nil {
+			t.Errorf("%q: expected an error", entry)
+		}
+	}
+}
+
+func TestRapidus_ReencryptColumns(t *testing.T) {
+	old, _ := encryption.New(oldTestKey)
+	e, _ := encryption.New(newTestKey, oldTestKey)
+
+	oldValue, _ := old.EncryptString("old")
+	currentValue, _ := e.EncryptString("current")
+	db := &reencryptDB{rows: [][]driver.Value{{int64(1), oldValue}, {int64(2), currentValue}, {int64(3), "not encrypted"}}}
+
+	app := newTestApp()
+	app.DB.Type = "postgres"
+	app.DB.Pool = sql.OpenDB(db)
+	defer app.DB.Pool.Close()
+
+	updated, err := app.ReencryptColumns(context.Background(), e, []string{"accounts.secret:account_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 1 {
+		t.Error("expected 1 value to be encrypted again, got", updated)
+	}
+
+	if !strings.Contains(db.query, `select "account_id", "secret" from "accounts"`) {
+		t.Error("rows not read by their key column:", db.query)
+	}
+	if len(db.execs) != 1 {
+		t.Fatal("expected 1 update, got", len(db.execs))
+	}
+
+	update := db.execs[0]
+	if update.query != `update "accounts" set "secret" = $1 where "account_id" = $2 and "secret" = $3` {
+		t.Error("unexpected update:", update.query)
+	}
+	if update.args[1] != int64(1) || update.args[2] != oldValue {
+		t.Error("update not conditional on the row and its old value:", update.args)
+	}
+	if s, err := e.DecryptString(update.args[0].(string)); err != nil || s != "old" || !e.IsCurrent(update.args[0].(string)) {
+		t.Error("value not encrypted again with the current key:", s, err)
+	}
+}
+
+// reencryptDB is a database with one table, that records the updates it is sent
+type reencryptDB struct {
+	rows  [][]driver.Value
+	query string
+	execs []reencryptExec
+}
+
+type reencryptExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (db *reencryptDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *reencryptDB) Driver() driver.Driver                        { return nil }
+func (db *reencryptDB) Prepare(query string) (driver.Stmt, error) {
+	return &reencryptStmt{db, query}, nil
+}
+func (db *reencryptDB) Close() error              { return nil }
+func (db *reencryptDB) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type reencryptStmt struct {
+	db    *reencryptDB
+	query string
+}
+
+func (s *reencryptStmt) Close() error  { return nil }
+func (s *reencryptStmt) NumInput() int { return -1 }
+
+func (s *reencryptStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.execs = append(s.db.execs, reencryptExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *reencryptStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.db.query = s.query
+	return &reencryptRows{rows: s.db.rows}, nil
+}
+
+type reencryptRows struct {
+	rows [][]driver.Value
+}
+
+func (r *reencryptRows) Columns() []string { return []string{"id", "value"} }
+func (r *reencryptRows) Close() error      { return nil }
+
+func (r *reencryptRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
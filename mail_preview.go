@@ -0,0 +1,203 @@
+package rapidus
+
+import (
+	"github.com/a-h/templ"
+	"github.com/fouched/rapidus/mailer"
+	"github.com/go-chi/chi/v5"
+	"github.com/justinas/nosurf"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// mailPreviewCSP lets the rendered html use the inline styles and remote images of an email,
+// but not run scripts
+const mailPreviewCSP = "default-src 'none'; style-src 'unsafe-inline' *; img-src * data:; font-src *; " +
+	"frame-ancestors 'self'; sandbox"
+
+// MailPreview returns the mail preview, which lists the templates in Mail.Templates and shows
+// each one rendered with the sample data in <name>.json next to it. A test message can be sent
+// through the configured driver. It is only served in debug mode, and is mounted with e.g.
+//
+//	mux.Mount("/mail-preview", app.MailPreview())
+func (r *Rapidus) MailPreview() http.Handler {
+	mux := chi.NewRouter()
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !r.Debug {
+				r.ErrorPage(w, req, http.StatusNotFound)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+
+	mux.Get("/", r.mailPreviewIndex)
+	mux.Get("/{template}", r.mailPreviewTemplate)
+	mux.Post("/{template}", r.mailPreviewTemplate)
+	mux.Get("/{template}/html", r.mailPreviewHTML)
+
+	return mux
+}
+
+type mailPreviewData struct {
+	Nonce     string
+	CSRFToken string
+	Templates []string
+	Name      string
+	Sample    string
+	Preview   *mailer.Preview
+	To        string
+	Sent      string
+	Error     error
+}
+
+func (r *Rapidus) mailPreviewIndex(w http.ResponseWriter, req *http.Request) {
+	// the links are relative to the mount path, so it has to end with a slash
+	if !strings.HasSuffix(req.URL.Path, "/") {
+		http.Redirect(w, req, req.URL.Path+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	data := mailPreviewData{Nonce: templ.GetNonce(req.Context())}
+	data.Templates, data.Error = r.Mail.TemplateNames()
+
+	r.renderMailPreview(w, "index", data)
+}
+
+func (r *Rapidus) mailPreviewTemplate(w http.ResponseWriter, req *http.Request) {
+	name, ok := r.mailPreviewName(w, req)
+	if !ok {
+		return
+	}
+
+	data := mailPreviewData{
+		Nonce:     templ.GetNonce(req.Context()),
+		CSRFToken: nosurf.Token(req),
+		Name:      name,
+	}
+
+	sample, err := r.Mail.SampleData(name)
+	if err != nil {
+		data.Error = err
+		r.renderMailPreview(w, "template", data)
+		return
+	}
+	if sample != nil {
+		data.Sample = name + ".json"
+	}
+	preview := r.Mail.Preview(name, sample)
+	data.Preview = &preview
+
+	if req.Method == http.MethodPost {
+		data.To = req.PostFormValue("to")
+		data.Error = r.Mail.Send(mailer.Message{
+			To:       data.To,
+			Subject:  "Preview: " + name,
+			Template: name,
+			Data:     sample,
+		})
+		if data.Error == nil {
+			data.Sent = data.To
+		}
+	}
+
+	r.renderMailPreview(w, "template", data)
+}
+
+// mailPreviewHTML serves the rendered html on its own, to show it in a frame without the styles
+// of the preview page
+func (r *Rapidus) mailPreviewHTML(w http.ResponseWriter, req *http.Request) {
+	name, ok := r.mailPreviewName(w, req)
+	if !ok {
+		return
+	}
+
+	sample, err := r.Mail.SampleData(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p := r.Mail.Preview(name, sample)
+	if p.HTMLError != nil {
+		http.Error(w, p.HTMLError.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Del("Content-Security-Policy-Report-Only")
+	w.Header().Set("Content-Security-Policy", mailPreviewCSP)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(p.HTML))
+}
+
+// mailPreviewName returns the template named in the URL, which has to be one of the templates
+// in Mail.Templates
+func (r *Rapidus) mailPreviewName(w http.ResponseWriter, req *http.Request) (string, bool) {
+	name := chi.URLParam(req, "template")
+
+	names, err := r.Mail.TemplateNames()
+	if err != nil || !slices.Contains(names, name) {
+		r.ErrorPage(w, req, http.StatusNotFound)
+		return "", false
+	}
+
+	return name, true
+}
+
+func (r *Rapidus) renderMailPreview(w http.ResponseWriter, page string, data mailPreviewData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := mailPreviewPage.ExecuteTemplate(w, page, data); err != nil {
+		r.ErrorLog.Println(err)
+	}
+}
+
+var mailPreviewPage = template.Must(template.New("mail-preview").Parse(`{{define "head"}}<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if .Name}}{{.Name}} - {{end}}Mail preview</title>
+<style nonce="{{.Nonce}}">
+body { font-family: sans-serif; margin: 2rem; color: #222; }
+h1 { font-size: 1.4rem; }
+h2 { font-size: 1.1rem; margin-top: 2rem; }
+.error { background: #ffe0e0; color: #b00020; padding: .5rem; white-space: pre-wrap; font-family: monospace; }
+.sent { background: #e0ffe0; padding: .5rem; }
+.muted { color: #555; }
+iframe { width: 100%; height: 40rem; border: 1px solid #ccc; }
+pre { background: #f6f6f6; padding: .5rem; overflow-x: auto; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "index"}}{{template "head" .}}<h1>Mail preview</h1>
+{{if .Error}}<div class="error">{{.Error}}</div>
+{{else if not .Templates}}<p class="muted">There are no templates.</p>
+{{end}}<ul>
+{{range .Templates}}<li><a href="{{.}}">{{.}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+{{end}}
+
+{{define "template"}}{{template "head" .}}<p><a href="./">All templates</a></p>
+<h1>{{.Name}}</h1>
+<p class="muted">{{if .Sample}}Rendered with the data in {{.Sample}}{{else}}Rendered without data, which is read from {{.Name}}.json{{end}}</p>
+{{if .Sent}}<div class="sent">Sent a test message to {{.Sent}}</div>
+{{end}}{{if .Error}}<div class="error">{{.Error}}</div>
+{{end}}<form method="post">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input type="text" name="to" value="{{.To}}" placeholder="you@there.com" required>
+<button type="submit">Send test</button>
+</form>
+{{with .Preview}}<h2>HTML</h2>
+{{if .HTMLError}}<div class="error">{{.HTMLError}}</div>
+{{else}}<iframe src="{{$.Name}}/html" title="{{$.Name}} html"></iframe>
+{{end}}<h2>Text</h2>
+{{if .TextError}}<div class="error">{{.TextError}}</div>
+{{else}}<pre>{{.Text}}</pre>
+{{end}}{{end}}</body>
+</html>
+{{end}}`))
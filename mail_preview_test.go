@@ -0,0 +1,105 @@
+package rapidus
+
+import (
+	"github.com/fouched/rapidus/mailer"
+	"github.com/go-chi/chi/v5"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newMailPreviewApp() (*Rapidus, *mailer.MemoryDriver, http.Handler) {
+	driver := &mailer.MemoryDriver{}
+	app := newTestApp()
+	app.Mail = mailer.Mail{Templates: "./mailer/testdata/mail", FromAddress: "me@here.com", Driver: driver}
+
+	mux := chi.NewRouter()
+	mux.Mount("/mail-preview", app.MailPreview())
+	return app, driver, app.NoSurf(mux)
+}
+
+func TestRapidus_MailPreview(t *testing.T) {
+	app, _, handler := newMailPreviewApp()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	for _, target := range []string{"/mail-preview/", "/mail-preview/preview", "/mail-preview/preview/html"} {
+		if w := get(target); w.Code != http.StatusNotFound {
+			t.Errorf("%s served outside debug mode, got %d", target, w.Code)
+		}
+	}
+
+	app.Debug = true
+
+	w := get("/mail-preview/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="preview">`) || !strings.Contains(w.Body.String(), `<a href="test">`) {
+		t.Errorf("expected the templates to be listed, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = get("/mail-preview/preview")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Rendered with the data in preview.json") {
+		t.Errorf("expected the preview page, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = get("/mail-preview/preview/html")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Security-Policy") != mailPreviewCSP {
+		t.Errorf("expected the html with the preview CSP, got %d %q", w.Code, w.Header().Get("Content-Security-Policy"))
+	}
+
+	for _, target := range []string{"/mail-preview/unknown", "/mail-preview/unknown/html", "/mail-preview/..%2f..%2fgo.mod",
+		"/mail-preview/..%2Fmail%2Ftest/html", "/mail-preview/preview.json", "/mail-preview/test.html.tmpl"} {
+		if w := get(target); w.Code != http.StatusNotFound {
+			t.Errorf("%s is not a template, expected 404 but got %d", target, w.Code)
+		}
+	}
+}
+
+func TestRapidus_MailPreview_send(t *testing.T) {
+	app, driver, handler := newMailPreviewApp()
+	app.Debug = true
+
+	post := func(form url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/mail-preview/preview", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(url.Values{"to": {"you@there.com"}}, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("test message posted without a CSRF token, got %d", w.Code)
+	}
+	if len(driver.Sent()) != 0 {
+		t.Fatal("test message sent without a CSRF token")
+	}
+
+	// the token is in the form of the page, and in the cookie set with it
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/mail-preview/preview", nil))
+	match := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatal("no CSRF token in the form:", w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+
+	w = post(url.Values{"to": {"you@there.com"}, "csrf_token": {html.UnescapeString(match[1])}}, cookies)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Sent a test message to you@there.com") {
+		t.Errorf("expected the test message to be sent, got %d %s", w.Code, w.Body.String())
+	}
+
+	email, ok := driver.Last()
+	if !ok || email.To[0].Address != "you@there.com" || email.Subject != "Preview: preview" || !strings.Contains(email.HTML, "Joe") {
+		t.Errorf("expected the preview sent with its sample data, got %+v", email)
+	}
+}
@@ -0,0 +1,50 @@
+package rapidus
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/fouched/rapidus/mailer"
+	"github.com/redis/go-redis/v9"
+	"os"
+)
+
+// OpenMailQueue opens the queue store of MAIL_QUEUE: database keeps queued mail in the
+// mail_queue table created by `rapidus make mailqueue`, redis in REDIS_HOST, and badger in
+// tmp/mailqueue. It returns nil when MAIL_QUEUE is not set. The returned function closes the
+// connection the store opened, if any
+func (r *Rapidus) OpenMailQueue() (mailer.QueueStore, func() error, error) {
+	noop := func() error { return nil }
+
+	switch os.Getenv("MAIL_QUEUE") {
+	case "":
+		return nil, noop, nil
+
+	case "database":
+		if r.DB.Pool == nil {
+			return nil, noop, errors.New("MAIL_QUEUE=database needs a database connection")
+		}
+		return mailer.NewSQLQueue(r.DB.Pool, r.DB.Type), noop, nil
+
+	case "redis":
+		if r.RedisClient != nil {
+			return mailer.NewRedisQueue(r.RedisClient, os.Getenv("REDIS_PREFIX")), noop, nil
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     os.Getenv("REDIS_HOST"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		})
+		return mailer.NewRedisQueue(client, os.Getenv("REDIS_PREFIX")), client.Close, nil
+
+	case "badger":
+		// the cache is in tmp/badger, and badger can't share a directory between databases
+		db, err := badger.Open(badger.DefaultOptions(r.RootPath + "/tmp/mailqueue").WithLogger(nil))
+		if err != nil {
+			return nil, noop, fmt.Errorf("could not open the badger mail queue, is the application still running? %w", err)
+		}
+		return mailer.NewBadgerQueue(db), db.Close, nil
+	}
+
+	return nil, noop, fmt.Errorf("unknown MAIL_QUEUE %s, only database, redis or badger accepted", os.Getenv("MAIL_QUEUE"))
+}
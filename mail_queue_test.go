@@ -0,0 +1,39 @@
+package rapidus
+
+import (
+	"testing"
+)
+
+func TestRapidus_OpenMailQueue(t *testing.T) {
+	app := newTestApp()
+	app.RootPath = t.TempDir()
+
+	t.Setenv("MAIL_QUEUE", "unknown")
+	if _, _, err := app.OpenMailQueue(); err == nil {
+		t.Error("expected an error for an unknown MAIL_QUEUE")
+	}
+
+	t.Setenv("MAIL_QUEUE", "database")
+	if _, _, err := app.OpenMailQueue(); err == nil {
+		t.Error("expected an error for MAIL_QUEUE=database without a database")
+	}
+
+	t.Setenv("MAIL_QUEUE", "badger")
+	store, closeQueue, err := app.OpenMailQueue()
+	if err != nil || store == nil {
+		t.Fatal("badger mail queue not opened:", err)
+	}
+	if _, _, err := app.OpenMailQueue(); err == nil {
+		t.Error("expected an error opening the badger mail queue twice")
+	}
+
+	// the directory can be opened again once the queue is closed
+	if err := closeQueue(); err != nil {
+		t.Fatal(err)
+	}
+	_, closeQueue, err = app.OpenMailQueue()
+	if err != nil {
+		t.Fatal("badger mail queue not closed:", err)
+	}
+	_ = closeQueue()
+}
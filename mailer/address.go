@@ -0,0 +1,146 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Priority is the importance of a message, shown by most mail clients
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+	PriorityLow
+)
+
+// headers returns the headers that set the priority, as sent by most mail clients
+func (p Priority) headers() map[string]string {
+	switch p {
+	case PriorityHigh:
+		return map[string]string{"X-Priority": "1 (Highest)", "X-MSMail-Priority": "High", "Importance": "High"}
+	case PriorityLow:
+		return map[string]string{"X-Priority": "5 (Lowest)", "X-MSMail-Priority": "Low", "Importance": "Low"}
+	}
+	return nil
+}
+
+// reservedHeaders are set from the fields of a message, and can't be set in Message.Headers
+var reservedHeaders = map[string]bool{
+	"From": true, "Sender": true, "To": true, "Cc": true, "Bcc": true, "Reply-To": true, "Return-Path": true, "Subject": true,
+	"Date": true, "Message-Id": true, "Mime-Version": true, "Content-Type": true, "Content-Transfer-Encoding": true,
+}
+
+// newEmail parses the addresses of a message and checks its headers, before its templates are
+// rendered. Invalid addresses and headers are permanent errors, as sending the message again
+// won't fix them
+func newEmail(msg Message) (*Email, error) {
+	email := &Email{ID: msg.ID, Subject: msg.Subject, Attachments: msg.Attachments}
+	var err error
+
+	if msg.From == "" {
+		return nil, Permanent(errors.New("mailer: no from address"))
+	}
+	email.From, err = mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("mailer: invalid from address %q: %w", msg.From, err))
+	}
+	if msg.FromName != "" {
+		email.From.Name = msg.FromName
+	}
+
+	if email.To, err = parseAddressList("to", msg.To); err != nil {
+		return nil, err
+	}
+	if email.Cc, err = parseAddressList("cc", msg.Cc...); err != nil {
+		return nil, err
+	}
+	if email.Bcc, err = parseAddressList("bcc", msg.Bcc...); err != nil {
+		return nil, err
+	}
+	if len(email.To)+len(email.Cc)+len(email.Bcc) == 0 {
+		return nil, Permanent(errors.New("mailer: no recipients"))
+	}
+
+	if msg.ReplyTo != "" {
+		email.ReplyTo, err = mail.ParseAddress(msg.ReplyTo)
+		if err != nil {
+			return nil, Permanent(fmt.Errorf("mailer: invalid reply-to address %q: %w", msg.ReplyTo, err))
+		}
+	}
+
+	email.Headers = msg.Priority.headers()
+	for name, value := range msg.Headers {
+		if err := checkHeader(name, value); err != nil {
+			return nil, Permanent(err)
+		}
+
+		if email.Headers == nil {
+			email.Headers = make(map[string]string)
+		}
+		email.Headers[name] = value
+	}
+
+	return email, nil
+}
+
+// parseAddressList parses addresses, each of which can be a comma separated list
+func parseAddressList(field string, lists ...string) ([]*mail.Address, error) {
+	var addresses []*mail.Address
+	for _, list := range lists {
+		if strings.TrimSpace(list) == "" {
+			continue
+		}
+
+		parsed, err := mail.ParseAddressList(list)
+		if err != nil {
+			return nil, Permanent(fmt.Errorf("mailer: invalid %s address %q: %w", field, list, err))
+		}
+		addresses = append(addresses, parsed...)
+	}
+	return addresses, nil
+}
+
+// checkHeader rejects header names that are not tokens, header values with line breaks, which
+// would allow injecting headers, and headers that are set from the fields of a message
+func checkHeader(name, value string) error {
+	if name == "" {
+		return errors.New("mailer: empty header name")
+	}
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || c == ':' {
+			return fmt.Errorf("mailer: invalid header name %q", name)
+		}
+	}
+
+	if reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+		return fmt.Errorf("mailer: header %s is set from the message, not from Headers", name)
+	}
+
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("mailer: header %s has a line break in its value", name)
+	}
+
+	return nil
+}
+
+// emails returns the addresses without their names
+func emails(addresses []*mail.Address) []string {
+	var result []string
+	for _, a := range addresses {
+		result = append(result, a.Address)
+	}
+	return result
+}
+
+// formatted returns the addresses with their names, encoded as in a mail header
+func formatted(addresses []*mail.Address) []string {
+	var result []string
+	for _, a := range addresses {
+		result = append(result, a.String())
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+package mailer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewEmail(t *testing.T) {
+	email, err := newEmail(Message{
+		From:     "me@here.com",
+		FromName: "Zoë Müller",
+		To:       `you@there.com, "Doe, John" <john@there.com>`,
+		Cc:       []string{"cc@there.com"},
+		Bcc:      []string{"bcc1@there.com, bcc2@there.com"},
+		ReplyTo:  "Support <support@here.com>",
+		Priority: PriorityHigh,
+		Headers:  map[string]string{"List-Unsubscribe": "<https://here.com/unsubscribe>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if from := email.From.String(); from != "=?utf-8?q?Zo=C3=AB_M=C3=BCller?= <me@here.com>" {
+		t.Errorf("expected an encoded display name, got %s", from)
+	}
+	if to := formatted(email.To); !reflect.DeepEqual(to, []string{"<you@there.com>", `"Doe, John" <john@there.com>`}) {
+		t.Errorf("unexpected to %v", to)
+	}
+	if cc := emails(email.Cc); !reflect.DeepEqual(cc, []string{"cc@there.com"}) {
+		t.Errorf("unexpected cc %v", cc)
+	}
+	if bcc := emails(email.Bcc); !reflect.DeepEqual(bcc, []string{"bcc1@there.com", "bcc2@there.com"}) {
+		t.Errorf("unexpected bcc %v", bcc)
+	}
+	if email.ReplyTo.Address != "support@here.com" {
+		t.Errorf("unexpected reply-to %v", email.ReplyTo)
+	}
+	if email.Headers["List-Unsubscribe"] != "<https://here.com/unsubscribe>" || email.Headers["X-Priority"] != "1 (Highest)" {
+		t.Errorf("unexpected headers %v", email.Headers)
+	}
+}
+
+func TestNewEmail_invalid(t *testing.T) {
+	var tests = []struct {
+		name string
+		msg  Message
+	}{
+		{"no from", Message{To: "you@there.com"}},
+		{"invalid from", Message{From: "me", To: "you@there.com"}},
+		{"no recipients", Message{From: "me@here.com"}},
+		{"invalid to", Message{From: "me@here.com", To: "not_an_email"}},
+		{"invalid cc", Message{From: "me@here.com", To: "you@there.com", Cc: []string{"you@"}}},
+		{"invalid reply-to", Message{From: "me@here.com", To: "you@there.com", ReplyTo: "support"}},
+		{"reserved header", Message{From: "me@here.com", To: "you@there.com", Headers: map[string]string{"subject": "hi"}}},
+		{"invalid header name", Message{From: "me@here.com", To: "you@there.com", Headers: map[string]string{"X Tag": "a"}}},
+		{"header injection", Message{From: "me@here.com", To: "you@there.com", Headers: map[string]string{"X-Tag": "a\r\nBcc: them@there.com"}}},
+	}
+
+	for _, e := range tests {
+		if _, err := newEmail(e.msg); err == nil || !IsPermanent(err) {
+			t.Errorf("%s: expected a permanent error, got %v", e.name, err)
+		}
+	}
+}
+
+func TestTransmission(t *testing.T) {
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com"}
+
+	email, err := m.render(Message{
+		To:       "You <you@there.com>",
+		Cc:       []string{"cc@there.com"},
+		ReplyTo:  "support@here.com",
+		Priority: PriorityLow,
+		Subject:  "test",
+		Template: "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := transmission(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(tx.Recipients, []string{"you@there.com"}) || !reflect.DeepEqual(tx.CC, []string{"cc@there.com"}) {
+		t.Errorf("unexpected recipients %v, cc %v", tx.Recipients, tx.CC)
+	}
+	if tx.Headers["Reply-To"] != "<support@here.com>" || tx.Headers["Importance"] != "Low" {
+		t.Errorf("unexpected headers %v", tx.Headers)
+	}
+}
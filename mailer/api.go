@@ -0,0 +1,91 @@
+package mailer
+
+import (
+	"github.com/ainsleyclark/go-mail/drivers"
+	"github.com/ainsleyclark/go-mail/mail"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterDriver("mailgun", goMailDriver("mailgun", drivers.NewMailgun))
+	RegisterDriver("sparkpost", goMailDriver("sparkpost", drivers.NewSparkPost))
+	RegisterDriver("sendgrid", goMailDriver("sendgrid", drivers.NewSendGrid))
+}
+
+// goMailDriver returns the factory of a driver that sends with a go-mail API client
+func goMailDriver(name string, newMailer func(mail.Config) (mail.Mailer, error)) DriverFactory {
+	return func(m *Mail) (Driver, error) {
+		return &apiDriver{name: name, mail: m, newMailer: newMailer}, nil
+	}
+}
+
+// apiDriver sends messages with a go-mail API client, configured by the API settings of a Mail
+type apiDriver struct {
+	name      string
+	mail      *Mail
+	newMailer func(mail.Config) (mail.Mailer, error)
+}
+
+func (d *apiDriver) Send(email *Email) error {
+	cfg := mail.Config{
+		URL:         d.mail.APIUrl,
+		APIKey:      d.mail.APIKey,
+		Domain:      d.mail.Domain,
+		FromAddress: email.From.Address,
+		FromName:    email.From.Name,
+	}
+
+	mailer, err := d.newMailer(cfg)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	tx, err := transmission(email)
+	if err != nil {
+		return err
+	}
+
+	// the sparkpost driver replaces the cc header it sets with the headers of the transmission
+	if d.name == "sparkpost" && len(tx.CC) > 0 && len(tx.Headers) > 0 {
+		tx.Headers["cc"] = strings.Join(tx.CC, ",")
+	}
+
+	_, err = mailer.Send(tx)
+	return err
+}
+
+// transmission builds the message sent by the API drivers. Recipients are sent without their
+// names, as not every API accepts them, and Reply-To is sent as a header
+func transmission(email *Email) (*mail.Transmission, error) {
+	tx := &mail.Transmission{
+		Recipients: emails(email.To),
+		CC:         emails(email.Cc),
+		BCC:        emails(email.Bcc),
+		Subject:    email.Subject,
+		HTML:       email.HTML,
+		PlainText:  email.Text,
+	}
+
+	if len(email.Headers) > 0 || email.ReplyTo != nil {
+		tx.Headers = make(map[string]string)
+		for name, value := range email.Headers {
+			tx.Headers[name] = value
+		}
+		if email.ReplyTo != nil {
+			tx.Headers["Reply-To"] = email.ReplyTo.String()
+		}
+	}
+
+	for _, x := range email.Attachments {
+		content, err := os.ReadFile(x)
+		if err != nil {
+			return nil, err
+		}
+
+		tx.Attachments = append(tx.Attachments, mail.Attachment{Filename: filepath.Base(x), Bytes: content})
+	}
+
+	return tx, nil
+}
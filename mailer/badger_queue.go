@@ -0,0 +1,237 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/dgraph-io/badger/v4"
+	"sort"
+	"time"
+)
+
+// BadgerQueue keeps queued messages in a badger database. Badger can only be opened by one
+// process, so the queue survives restarts but can't be shared by several instances: use SQLQueue
+// or RedisQueue for that. Reserve reads every message, which is fine for the queues of a single
+// instance
+type BadgerQueue struct {
+	DB *badger.DB
+	// Prefix is prepended to the keys of messages, mailqueue: when not set
+	Prefix string
+}
+
+// NewBadgerQueue returns a queue in db
+func NewBadgerQueue(db *badger.DB) *BadgerQueue {
+	return &BadgerQueue{DB: db, Prefix: "mailqueue:"}
+}
+
+func (q *BadgerQueue) prefix() []byte {
+	if q.Prefix == "" {
+		return []byte("mailqueue:")
+	}
+	return []byte(q.Prefix)
+}
+
+func (q *BadgerQueue) key(id string) []byte {
+	return append(q.prefix(), id...)
+}
+
+func (q *BadgerQueue) put(txn *badger.Txn, qm *QueuedMessage) error {
+	value, err := json.Marshal(qm)
+	if err != nil {
+		return Permanent(err)
+	}
+	return txn.Set(q.key(qm.Message.ID), value)
+}
+
+func (q *BadgerQueue) get(txn *badger.Txn, id string) (*QueuedMessage, error) {
+	item, err := txn.Get(q.key(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var qm QueuedMessage
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &qm)
+	})
+	return &qm, err
+}
+
+// each calls fn with every message, oldest first
+func (q *BadgerQueue) each(txn *badger.Txn, fn func(*QueuedMessage) error) error {
+	var messages []*QueuedMessage
+
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: q.prefix()})
+	for it.Rewind(); it.Valid(); it.Next() {
+		var qm QueuedMessage
+		err := it.Item().Value(func(val []byte) error {
+			return json.Unmarshal(val, &qm)
+		})
+		if err != nil {
+			it.Close()
+			return err
+		}
+		messages = append(messages, &qm)
+	}
+	it.Close()
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+	})
+
+	for _, qm := range messages {
+		if err := fn(qm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update changes a message in a transaction, which is retried when it conflicts with another
+func (q *BadgerQueue) update(fn func(txn *badger.Txn) error) error {
+	var err error
+	for i := 0; i < 10; i++ {
+		err = q.DB.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// Push adds a message that is due now
+func (q *BadgerQueue) Push(_ context.Context, msg Message) error {
+	now := time.Now()
+	qm := &QueuedMessage{Message: msg, State: StateQueued, AvailableAt: now, CreatedAt: now}
+
+	return q.update(func(txn *badger.Txn) error {
+		return q.put(txn, qm)
+	})
+}
+
+// Reserve claims the message that has been due the longest for visibility
+func (q *BadgerQueue) Reserve(_ context.Context, visibility time.Duration) (*QueuedMessage, error) {
+	var reserved *QueuedMessage
+
+	err := q.update(func(txn *badger.Txn) error {
+		reserved = nil
+		now := time.Now()
+
+		err := q.each(txn, func(qm *QueuedMessage) error {
+			if qm.State == StateQueued && !qm.AvailableAt.After(now) &&
+				(reserved == nil || qm.AvailableAt.Before(reserved.AvailableAt)) {
+				reserved = qm
+			}
+			return nil
+		})
+		if err != nil || reserved == nil {
+			return err
+		}
+
+		reserved.Attempts++
+		reserved.AvailableAt = now.Add(visibility)
+		return q.put(txn, reserved)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reserved == nil {
+		return nil, ErrNoMessage
+	}
+
+	return reserved, nil
+}
+
+// Complete removes a message that was sent
+func (q *BadgerQueue) Complete(_ context.Context, id string) error {
+	return q.update(func(txn *badger.Txn) error {
+		return txn.Delete(q.key(id))
+	})
+}
+
+// Release makes a reserved message due again at a later time
+func (q *BadgerQueue) Release(_ context.Context, id, lastError string, at time.Time) error {
+	return q.update(func(txn *badger.Txn) error {
+		qm, err := q.get(txn, id)
+		if err != nil {
+			return err
+		}
+
+		qm.LastError = lastError
+		qm.AvailableAt = at
+		return q.put(txn, qm)
+	})
+}
+
+// Bury marks a message as failed
+func (q *BadgerQueue) Bury(_ context.Context, id, lastError string) error {
+	return q.update(func(txn *badger.Txn) error {
+		qm, err := q.get(txn, id)
+		if err != nil {
+			return err
+		}
+
+		qm.State = StateFailed
+		qm.LastError = lastError
+		return q.put(txn, qm)
+	})
+}
+
+// List returns the messages in a state, or all messages when state is empty, oldest first
+func (q *BadgerQueue) List(_ context.Context, state State) ([]QueuedMessage, error) {
+	var messages []QueuedMessage
+
+	err := q.DB.View(func(txn *badger.Txn) error {
+		return q.each(txn, func(qm *QueuedMessage) error {
+			if state == "" || qm.State == state {
+				messages = append(messages, *qm)
+			}
+			return nil
+		})
+	})
+
+	return messages, err
+}
+
+// Retry makes a failed message due again, or all failed messages when id is empty
+func (q *BadgerQueue) Retry(_ context.Context, id string) (int, error) {
+	n := 0
+
+	err := q.update(func(txn *badger.Txn) error {
+		n = 0
+		now := time.Now()
+
+		return q.each(txn, func(qm *QueuedMessage) error {
+			if qm.State != StateFailed || (id != "" && qm.Message.ID != id) {
+				return nil
+			}
+
+			qm.State = StateQueued
+			qm.Attempts = 0
+			qm.AvailableAt = now
+			n++
+			return q.put(txn, qm)
+		})
+	})
+
+	return n, err
+}
+
+// Purge removes the messages in a state, or all messages when state is empty
+func (q *BadgerQueue) Purge(_ context.Context, state State) (int, error) {
+	n := 0
+
+	err := q.update(func(txn *badger.Txn) error {
+		n = 0
+
+		return q.each(txn, func(qm *QueuedMessage) error {
+			if state != "" && qm.State != state {
+				return nil
+			}
+
+			n++
+			return txn.Delete(q.key(qm.Message.ID))
+		})
+	})
+
+	return n, err
+}
@@ -0,0 +1,262 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"github.com/fouched/rapidus/token"
+	"math/rand/v2"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// State is where a message is in its delivery
+type State string
+
+const (
+	StateQueued   State = "queued"
+	StateSending  State = "sending"
+	StateRetrying State = "retrying"
+	StateSent     State = "sent"
+	StateFailed   State = "failed"
+)
+
+// Status is the delivery status of a queued message
+type Status struct {
+	ID        string
+	State     State
+	Attempts  int
+	Error     error
+	UpdatedAt time.Time
+}
+
+// DeadLetter is a message that could not be delivered
+type DeadLetter struct {
+	Message  Message
+	Error    string
+	Attempts int
+	FailedAt time.Time
+}
+
+// DeadLetterStore keeps messages that could not be delivered, so they can be inspected and retried
+type DeadLetterStore interface {
+	Add(DeadLetter) error
+}
+
+// MemoryDeadLetters keeps the most recent dead letters in memory
+type MemoryDeadLetters struct {
+	// Max is the number of dead letters kept, 100 when not set
+	Max int
+
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// Add keeps a dead letter, dropping the oldest one when Max is reached
+func (d *MemoryDeadLetters) Add(letter DeadLetter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	max := d.Max
+	if max <= 0 {
+		max = 100
+	}
+
+	d.letters = append(d.letters, letter)
+	if len(d.letters) > max {
+		d.letters = d.letters[len(d.letters)-max:]
+	}
+
+	return nil
+}
+
+// List returns the dead letters, oldest first
+func (d *MemoryDeadLetters) List() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]DeadLetter(nil), d.letters...)
+}
+
+// permanentError is an error that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as permanent, so the message is not retried. Drivers use it for
+// errors such as rejected recipients or invalid credentials
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// IsPermanent reports whether retrying a message that failed with err is pointless: errors
+// marked with Permanent, and SMTP 5xx replies. Network errors, timeouts, SMTP 4xx replies and
+// any other errors are transient
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return true
+	}
+
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+
+	return false
+}
+
+// statusRetention is how long the status of a delivered or failed message is kept
+const statusRetention = time.Hour
+
+// statuses tracks the delivery status of queued messages
+type statuses struct {
+	mu sync.Mutex
+	m  map[string]*Status
+}
+
+// trackerMu guards creating the status tracker of a Mail
+var trackerMu sync.Mutex
+
+func (m *Mail) tracker() *statuses {
+	trackerMu.Lock()
+	defer trackerMu.Unlock()
+
+	if m.statuses == nil {
+		m.statuses = &statuses{m: make(map[string]*Status)}
+	}
+	return m.statuses
+}
+
+func (s *statuses) set(id string, state State, attempts int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.m[id] = &Status{ID: id, State: state, Attempts: attempts, Error: err, UpdatedAt: now}
+
+	// forget messages that were done with a while ago
+	if state == StateSent || state == StateFailed {
+		for key, status := range s.m {
+			if (status.State == StateSent || status.State == StateFailed) && now.Sub(status.UpdatedAt) > statusRetention {
+				delete(s.m, key)
+			}
+		}
+	}
+}
+
+// Queue queues a message for delivery by the workers started by ListenForMail, and returns its
+// ID, which can be used to get its Status. The ID of the message is set when it is empty. When
+// Mail has a Store, the message is added to it, and an error is returned when that fails
+func (m *Mail) Queue(msg Message) (string, error) {
+	if msg.ID == "" {
+		msg.ID = token.UUIDv7()
+	}
+
+	if m.Store != nil {
+		if err := m.Store.Push(context.Background(), msg); err != nil {
+			return msg.ID, err
+		}
+		m.tracker().set(msg.ID, StateQueued, 0, nil)
+		return msg.ID, nil
+	}
+
+	m.tracker().set(msg.ID, StateQueued, 0, nil)
+	m.Jobs <- msg
+
+	return msg.ID, nil
+}
+
+// Status returns the delivery status of a queued message. Statuses are kept for an hour after
+// a message was delivered or failed
+func (m *Mail) Status(id string) (Status, bool) {
+	s := m.tracker()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.m[id]
+	if !ok {
+		return Status{}, false
+	}
+	return *status, true
+}
+
+// delivery is a message on its way to the workers, with the number of times it was tried
+type delivery struct {
+	msg      Message
+	attempts int
+}
+
+// deliver makes one attempt to send a message. It returns whether the message is done with, or
+// has to be tried again after the returned wait. Messages that can't be delivered are added to
+// the dead letters
+func (m *Mail) deliver(d delivery) (Result, time.Duration, bool) {
+	msg := d.msg
+	attempt := d.attempts + 1
+	s := m.tracker()
+
+	maxAttempts := m.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	s.set(msg.ID, StateSending, attempt, nil)
+	err := m.Send(msg)
+	if err == nil {
+		s.set(msg.ID, StateSent, attempt, nil)
+		return Result{Success: true, ID: msg.ID, Attempts: attempt}, 0, true
+	}
+
+	if !IsPermanent(err) && attempt < maxAttempts {
+		s.set(msg.ID, StateRetrying, attempt, err)
+		return Result{Error: err, ID: msg.ID, Attempts: attempt}, m.backoff(attempt), false
+	}
+
+	s.set(msg.ID, StateFailed, attempt, err)
+	if m.DeadLetters != nil {
+		_ = m.DeadLetters.Add(DeadLetter{Message: msg, Error: err.Error(), Attempts: attempt, FailedAt: time.Now()})
+	}
+
+	return Result{Success: false, Error: err, ID: msg.ID, Attempts: attempt}, 0, true
+}
+
+// backoff returns how long to wait after a failed attempt: Backoff doubled for every attempt,
+// at most a minute, with 20% jitter so failed messages are not all retried at once
+func (m *Mail) backoff(attempt int) time.Duration {
+	base := m.Backoff
+	if base <= 0 {
+		base = 2 * time.Second
+	}
+
+	wait := base << (attempt - 1)
+	if wait > time.Minute || wait <= 0 {
+		wait = time.Minute
+	}
+
+	jitter := time.Duration(rand.Int64N(int64(wait)/5 + 1))
+	return wait - wait/10 + jitter
+}
+
+// report makes a result available without blocking the worker: it is passed to OnResult and
+// sent on Results when a receiver is waiting or there is room in its buffer, and dropped otherwise
+func (m *Mail) report(res Result) {
+	if m.OnResult != nil {
+		m.OnResult(res)
+	}
+
+	select {
+	case m.Results <- res:
+	default:
+	}
+}
@@ -0,0 +1,172 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestIsPermanent(t *testing.T) {
+	var tests = []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{"marked permanent", Permanent(errors.New("rejected")), true},
+		{"wrapped permanent", fmt.Errorf("sending: %w", Permanent(errors.New("rejected"))), true},
+		{"smtp 5xx", &textproto.Error{Code: 550, Msg: "mailbox unavailable"}, true},
+		{"smtp 4xx", &textproto.Error{Code: 421, Msg: "try again later"}, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, false},
+		{"smtp client", errors.New("Mail Error: No recipient specified"), false},
+		{"unknown", errors.New("something went wrong"), false},
+	}
+
+	for _, e := range tests {
+		if IsPermanent(e.err) != e.permanent {
+			t.Errorf("%s: expected permanent to be %v", e.name, e.permanent)
+		}
+	}
+}
+
+func TestMail_backoff(t *testing.T) {
+	m := Mail{Backoff: time.Second}
+
+	for attempt, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: time.Minute} {
+		wait := m.backoff(attempt)
+		if wait < expected*9/10 || wait > expected*11/10 {
+			t.Errorf("attempt %d: expected about %s but got %s", attempt, expected, wait)
+		}
+	}
+}
+
+func TestMemoryDeadLetters(t *testing.T) {
+	d := MemoryDeadLetters{Max: 2}
+	for i := 1; i <= 3; i++ {
+		_ = d.Add(DeadLetter{Message: Message{ID: fmt.Sprint(i)}})
+	}
+
+	letters := d.List()
+	if len(letters) != 2 || letters[0].Message.ID != "2" || letters[1].Message.ID != "3" {
+		t.Errorf("expected the 2 most recent dead letters, got %v", letters)
+	}
+}
+
+// TestMail_deliver sends to a port nobody listens on, so every attempt fails with a transient error
+func TestMail_deliver(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	deadLetters := &MemoryDeadLetters{}
+	m := Mail{
+		Templates:   "./testdata/mail",
+		Host:        "127.0.0.1",
+		Port:        port,
+		Encryption:  "none",
+		FromAddress: "me@here.com",
+		Jobs:        make(chan Message),
+		MaxAttempts: 3,
+		Backoff:     10 * time.Millisecond,
+		DeadLetters: deadLetters,
+	}
+
+	results := make(chan Result, 1)
+	m.OnResult = func(res Result) { results <- res }
+	go m.ListenForMail()
+	defer close(m.Jobs)
+
+	id, _ := m.Queue(Message{To: "you@there.com", Subject: "test", Template: "test"})
+
+	res := <-results
+	if res.Success || res.ID != id || res.Attempts != 3 {
+		t.Errorf("expected 3 failed attempts for %s, got %+v", id, res)
+	}
+
+	status, ok := m.Status(id)
+	if !ok || status.State != StateFailed || status.Attempts != 3 {
+		t.Errorf("expected a failed status, got %+v", status)
+	}
+
+	if letters := deadLetters.List(); len(letters) != 1 || letters[0].Message.ID != id {
+		t.Errorf("expected the message in the dead letters, got %v", letters)
+	}
+
+	// a missing template is permanent, so it is not retried
+	id, _ = m.Queue(Message{To: "you@there.com", Subject: "test", Template: "missing"})
+	if res = <-results; res.Attempts != 1 {
+		t.Errorf("expected 1 attempt for %s, got %+v", id, res)
+	}
+}
+
+// failingDriver fails to send to fail@there.com with a transient error
+type failingDriver struct{}
+
+func (failingDriver) Send(email *Email) error {
+	if email.To[0].Address == "fail@there.com" {
+		return errors.New("try again later")
+	}
+	return nil
+}
+
+func TestMail_ListenForMail_retry(t *testing.T) {
+	m := Mail{
+		Templates:   "./testdata/mail",
+		FromAddress: "me@here.com",
+		Jobs:        make(chan Message),
+		Driver:      failingDriver{},
+		MaxAttempts: 2,
+		Backoff:     200 * time.Millisecond,
+	}
+
+	results := make(chan Result, 2)
+	m.OnResult = func(res Result) { results <- res }
+	done := make(chan struct{})
+	go func() {
+		m.ListenForMail()
+		close(done)
+	}()
+
+	failing, _ := m.Queue(Message{To: "fail@there.com", Subject: "test", Template: "test"})
+	sent, _ := m.Queue(Message{To: "you@there.com", Subject: "test", Template: "test"})
+	close(m.Jobs)
+
+	// the only worker sends the second message while the first one waits for its retry
+	if res := <-results; res.ID != sent || !res.Success {
+		t.Errorf("expected %s to be sent first, got %+v", sent, res)
+	}
+	if res := <-results; res.ID != failing || res.Success || res.Attempts != 2 {
+		t.Errorf("expected 2 failed attempts for %s, got %+v", failing, res)
+	}
+
+	// the workers stop once the retries are done with
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("ListenForMail did not return after Jobs was closed")
+	}
+}
+
+func TestSMTPDriver_invalidMessage(t *testing.T) {
+	d := smtpDriver{mail: &Mail{Host: "127.0.0.1", Port: 1, Encryption: "none"}}
+
+	err := d.Send(&Email{
+		From: &mail.Address{Address: "me@here.com"},
+		To:   []*mail.Address{{Address: "not_an_email"}},
+	})
+	if err == nil || !IsPermanent(err) {
+		t.Error("expected a permanent error for an invalid address, got", err)
+	}
+
+	err = d.Send(&Email{
+		From:        &mail.Address{Address: "me@here.com"},
+		To:          []*mail.Address{{Address: "you@there.com"}},
+		Attachments: []string{"./testdata/missing.pdf"},
+	})
+	if err == nil || !IsPermanent(err) {
+		t.Error("expected a permanent error for a missing attachment, got", err)
+	}
+}
@@ -0,0 +1,128 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/fouched/rapidus/token"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LogDriver prints messages instead of sending them, for development
+type LogDriver struct {
+	// Out is where messages are printed, os.Stdout when not set
+	Out io.Writer
+}
+
+func (d *LogDriver) Send(email *Email) error {
+	out := d.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "----- mail %s -----\n", email.ID)
+	fmt.Fprintf(&b, "From: %s\n", email.From)
+	writeAddressHeader(&b, "To", email.To)
+	writeAddressHeader(&b, "Cc", email.Cc)
+	writeAddressHeader(&b, "Bcc", email.Bcc)
+	if email.ReplyTo != nil {
+		fmt.Fprintf(&b, "Reply-To: %s\n", email.ReplyTo)
+	}
+	fmt.Fprintf(&b, "Subject: %s\n", email.Subject)
+
+	for _, name := range sortedHeaders(email.Headers) {
+		fmt.Fprintf(&b, "%s: %s\n", name, email.Headers[name])
+	}
+
+	for _, x := range email.Attachments {
+		fmt.Fprintf(&b, "Attachment: %s\n", x)
+	}
+
+	fmt.Fprintf(&b, "\n%s\n----- html -----\n%s\n-----\n", email.Text, email.HTML)
+
+	// one write per message, so messages sent at the same time don't interleave
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+func writeAddressHeader(b *strings.Builder, name string, addresses []*mail.Address) {
+	if len(addresses) > 0 {
+		fmt.Fprintf(b, "%s: %s\n", name, strings.Join(formatted(addresses), ", "))
+	}
+}
+
+// FileDriver writes messages to .eml files instead of sending them, for development. The files
+// hold the complete message, Bcc included, and open in most mail clients
+type FileDriver struct {
+	// Dir is where the files are written, tmp/mail when not set
+	Dir string
+}
+
+func (d *FileDriver) Send(email *Email) error {
+	dir := d.Dir
+	if dir == "" {
+		dir = "tmp/mail"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	msg := mimeMessage(email, true)
+	if msg.Error != nil {
+		return Permanent(msg.Error)
+	}
+
+	// ULIDs sort by time, so the files are listed in the order they were sent
+	name := token.ULID()
+	if email.ID != "" && !strings.ContainsAny(email.ID, `/\.`) {
+		name += "_" + email.ID
+	}
+
+	return os.WriteFile(filepath.Join(dir, name+".eml"), []byte(msg.GetMessage()), 0644)
+}
+
+// MemoryDriver keeps messages instead of sending them, so tests can check the mail that was sent
+type MemoryDriver struct {
+	mu   sync.Mutex
+	sent []Email
+}
+
+func (d *MemoryDriver) Send(email *Email) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sent = append(d.sent, *email)
+	return nil
+}
+
+// Sent returns the messages that were sent, oldest first
+func (d *MemoryDriver) Sent() []Email {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]Email(nil), d.sent...)
+}
+
+// Last returns the message that was sent last
+func (d *MemoryDriver) Last() (Email, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.sent) == 0 {
+		return Email{}, false
+	}
+	return d.sent[len(d.sent)-1], true
+}
+
+// Reset forgets the messages that were sent
+func (d *MemoryDriver) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sent = nil
+}
@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var devMessage = Message{
+	ID:       "abc",
+	To:       "You <you@there.com>",
+	Bcc:      []string{"them@there.com"},
+	Subject:  "test",
+	Template: "test",
+	Headers:  map[string]string{"List-Unsubscribe": "<https://here.com/unsubscribe>"},
+}
+
+func TestLogDriver(t *testing.T) {
+	var out strings.Builder
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", Driver: &LogDriver{Out: &out}}
+
+	if err := m.Send(devMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"To: \"You\" <you@there.com>", "Bcc: <them@there.com>", "Subject: test",
+		"List-Unsubscribe: <https://here.com/unsubscribe>", "Enter your message content here"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the log, got %s", expected, out.String())
+		}
+	}
+}
+
+func TestFileDriver(t *testing.T) {
+	dir := t.TempDir()
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", FromName: "Joe", Driver: &FileDriver{Dir: dir}}
+
+	if err := m.Send(devMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*_abc.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected an .eml file, got %v", files)
+	}
+
+	content, _ := os.ReadFile(files[0])
+	for _, expected := range []string{"From: \"Joe\" <me@here.com>", "To: \"You\" <you@there.com>", "Bcc: <them@there.com>",
+		"Message-Id: <abc@here.com>", "Content-Type: multipart/alternative", "Content-Type: text/plain"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("expected %q in the file, got %s", expected, content)
+		}
+	}
+}
+
+func TestMemoryDriver(t *testing.T) {
+	driver := &MemoryDriver{}
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", Driver: driver}
+
+	if _, ok := driver.Last(); ok {
+		t.Error("expected no messages")
+	}
+
+	_ = m.Send(devMessage)
+	_ = m.Send(Message{To: "them@there.com", Subject: "second", Template: "test"})
+
+	if sent := driver.Sent(); len(sent) != 2 || sent[0].ID != "abc" {
+		t.Errorf("expected 2 messages, got %v", sent)
+	}
+	if last, ok := driver.Last(); !ok || last.Subject != "second" || last.To[0].Address != "them@there.com" {
+		t.Errorf("unexpected last message %+v", last)
+	}
+
+	driver.Reset()
+	if len(driver.Sent()) != 0 {
+		t.Error("expected no messages after a reset")
+	}
+}
+
+func TestMail_driver(t *testing.T) {
+	var tests = []struct {
+		api      string
+		expected Driver
+	}{
+		{"", &smtpDriver{}},
+		{"smtp", &smtpDriver{}},
+		{"log", &LogDriver{}},
+		{"file", &FileDriver{}},
+		{"mailgun", &apiDriver{}},
+	}
+
+	for _, e := range tests {
+		m := Mail{API: e.api}
+		driver, err := m.driver()
+		if err != nil || fmt.Sprintf("%T", driver) != fmt.Sprintf("%T", e.expected) {
+			t.Errorf("%q: expected a %T, got %T, %v", e.api, e.expected, driver, err)
+		}
+	}
+
+	m := Mail{API: "unknown"}
+	if _, err := m.driver(); err == nil {
+		t.Error("expected an error for an unknown api")
+	}
+}
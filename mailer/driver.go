@@ -0,0 +1,123 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Driver delivers rendered messages
+type Driver interface {
+	Send(email *Email) error
+}
+
+// Email is a message with its addresses parsed and its templates rendered, which is what a
+// Driver sends
+type Email struct {
+	ID      string
+	From    *mail.Address
+	To      []*mail.Address
+	Cc      []*mail.Address
+	Bcc     []*mail.Address
+	ReplyTo *mail.Address
+	// Headers holds the custom headers and the priority headers
+	Headers map[string]string
+	Subject string
+	HTML    string
+	Text    string
+	// Attachments are the paths of the attached files
+	Attachments []string
+}
+
+// DriverFactory creates a driver from the settings of a Mail
+type DriverFactory func(m *Mail) (Driver, error)
+
+var (
+	driversMu sync.RWMutex
+	factories = make(map[string]DriverFactory)
+)
+
+// RegisterDriver makes a driver available to be chosen with Mail.API, which is MAILER_API in
+// .env. Applications register their own transports with it, usually in an init function. It
+// panics when a driver is registered twice with the same name
+func RegisterDriver(name string, factory DriverFactory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("mailer: RegisterDriver factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("mailer: RegisterDriver called twice for driver " + name)
+	}
+	factories[name] = factory
+}
+
+// Drivers returns the names of the registered drivers, sorted
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterDriver("smtp", func(m *Mail) (Driver, error) { return &smtpDriver{mail: m}, nil })
+	RegisterDriver("log", func(m *Mail) (Driver, error) { return &LogDriver{}, nil })
+	RegisterDriver("file", func(m *Mail) (Driver, error) { return &FileDriver{}, nil })
+}
+
+// driver returns the Driver of m, or creates the registered driver named by API when it is not
+// set. SMTP is used when API is empty
+func (m *Mail) driver() (Driver, error) {
+	if m.Driver != nil {
+		return m.Driver, nil
+	}
+
+	name := m.API
+	if name == "" {
+		name = "smtp"
+	}
+
+	driversMu.RLock()
+	factory, ok := factories[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, Permanent(fmt.Errorf("unknown email api %s, only %s accepted", name, strings.Join(Drivers(), ", ")))
+	}
+
+	driver, err := factory(m)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	return driver, nil
+}
+
+// render fills in the default sender of a message, checks its addresses and renders its templates
+func (m *Mail) render(msg Message) (*Email, error) {
+	msg = m.sanitizeMessage(msg)
+
+	email, err := newEmail(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	email.HTML, err = m.buildHTMLMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	email.Text, err = m.buildPlainTextMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return email, nil
+}
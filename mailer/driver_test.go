@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"testing"
+)
+
+type recordingDriver struct {
+	subjects []string
+}
+
+func (d *recordingDriver) Send(email *Email) error {
+	d.subjects = append(d.subjects, email.Subject)
+	return nil
+}
+
+func TestRegisterDriver(t *testing.T) {
+	driver := &recordingDriver{}
+	RegisterDriver("test-recording", func(m *Mail) (Driver, error) { return driver, nil })
+
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", API: "test-recording"}
+	if err := m.Send(Message{To: "you@there.com", Subject: "test", Template: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(driver.subjects) != 1 || driver.subjects[0] != "test" {
+		t.Errorf("expected the registered driver to send the message, got %v", driver.subjects)
+	}
+
+	found := false
+	for _, name := range Drivers() {
+		found = found || name == "test-recording"
+	}
+	if !found {
+		t.Errorf("expected test-recording in %v", Drivers())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic when a driver is registered twice")
+		}
+	}()
+	RegisterDriver("test-recording", func(m *Mail) (Driver, error) { return driver, nil })
+}
+
+// TestMail_Send_api checks that an API is used when it has a key but no URL
+func TestMail_Send_api(t *testing.T) {
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", API: "postmark", APIKey: "token"}
+
+	driver, err := m.driver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := driver.(*PostmarkDriver); !ok {
+		t.Errorf("expected the postmark driver, got %T", driver)
+	}
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultHTTPClient is used by the API drivers that have no Client
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// newJSONRequest returns a POST request of body as JSON, and the JSON, which some APIs sign
+func newJSONRequest(url string, body interface{}) (*http.Request, []byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, Permanent(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	return req, payload, nil
+}
+
+// doJSON sends a request and decodes the JSON response into result, when it is not nil
+func doJSON(provider string, client *http.Client, req *http.Request, result interface{}) error {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(provider, resp, body)
+	}
+
+	if result != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("%s: invalid response: %w", provider, err)
+		}
+	}
+
+	return nil
+}
+
+// statusError returns the error of an API response. Rate limits, timeouts and server errors
+// are transient, other errors such as rejected messages or invalid credentials are permanent
+func statusError(provider string, resp *http.Response, body []byte) error {
+	detail := strings.TrimSpace(string(body))
+	if len(detail) > 512 {
+		detail = detail[:512]
+	}
+	err := fmt.Errorf("%s: %s: %s", provider, resp.Status, detail)
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+		return err
+	}
+	return Permanent(err)
+}
+
+// attachment is an attached file, as sent by the API drivers
+type attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+func readAttachments(paths []string) ([]attachment, error) {
+	var attachments []attachment
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		attachments = append(attachments, attachment{Name: filepath.Base(path), ContentType: contentType, Content: content})
+	}
+	return attachments, nil
+}
@@ -3,13 +3,11 @@ package mailer
 import (
 	"bytes"
 	"fmt"
-	"github.com/ainsleyclark/go-mail/drivers"
-	"github.com/ainsleyclark/go-mail/mail"
+	"github.com/fouched/rapidus/token"
 	"github.com/vanng822/go-premailer/premailer"
-	smtpmail "github.com/xhit/go-simple-mail/v2"
 	"html/template"
-	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -28,12 +26,46 @@ type Mail struct {
 	API         string
 	APIKey      string
 	APIUrl      string
+	// Driver delivers the messages. When not set, it is the registered driver named by API, or
+	// smtp when API is empty
+	Driver Driver
+	// Workers is the number of messages sent at the same time, 1 when not set
+	Workers int
+	// MaxAttempts is how often a message is tried before it is a dead letter, 1 when not set
+	MaxAttempts int
+	// Backoff is the wait before the first retry, which doubles for every retry
+	Backoff time.Duration
+	// DeadLetters keeps messages that could not be delivered
+	DeadLetters DeadLetterStore
+	// OnResult is called with the result of every queued message, and with the errors of Store,
+	// which have no ID
+	OnResult func(Result)
+	// Store keeps queued messages so they survive restarts, and lets several instances share
+	// the queue. Messages are only kept in memory when not set
+	Store QueueStore
+	// Visibility is how long a message taken from Store is hidden from other workers while it
+	// is sent, 5 minutes when not set
+	Visibility time.Duration
+
+	statuses *statuses
 }
 
 type Message struct {
-	From        string
-	FromName    string
-	To          string
+	// ID identifies a queued message, and is set by Queue when empty
+	ID       string
+	From     string
+	FromName string
+	// To, Cc and Bcc are addresses such as you@there.com or "You <you@there.com>", and can be
+	// comma separated lists of addresses. A message needs at least one recipient
+	To      string
+	Cc      []string
+	Bcc     []string
+	ReplyTo string
+	// Priority sets the headers that mail clients show as the importance of a message
+	Priority Priority
+	// Headers are added to the message, e.g. List-Unsubscribe. Headers that are set from other
+	// fields, such as From or Subject, can't be set here
+	Headers     map[string]string
 	Subject     string
 	Template    string
 	Attachments []string
@@ -41,191 +73,110 @@ type Message struct {
 }
 
 type Result struct {
-	Success bool
-	Error   error
+	Success  bool
+	Error    error
+	ID       string
+	Attempts int
 }
 
-// ListenForMail listens to the mail channel and sends email with it receives a payload.
-// It runs continually in the background, and sends error/success messages back on the
-// Results channel.
-// Note: that if api and api key are set, it will prefer using an api to send mail iso SMTP
+// ListenForMail starts the workers that send the messages on the Jobs channel, and runs until
+// Jobs is closed. Transient failures are retried with backoff, and messages that can't be
+// delivered go to DeadLetters. Results are passed to OnResult, and sent on the Results channel
+// without blocking, so they are dropped when nobody reads them. When Mail has a Store, the
+// workers send the messages in it instead, and messages sent on Jobs are added to it
 func (m *Mail) ListenForMail() {
-	for {
-		msg := <-m.Jobs
-		err := m.Send(msg)
-		if err != nil {
-			m.Results <- Result{false, err}
-		} else {
-			m.Results <- Result{true, nil}
-		}
+	workers := m.Workers
+	if workers <= 0 {
+		workers = 1
 	}
-}
 
-// Send allows sending of mail directly
-// Note: that if api and api key are set, it will prefer using an api to send mail iso SMTP
-func (m *Mail) Send(msg Message) error {
-	if len(m.API) > 0 && len(m.APIKey)&len(m.APIUrl) > 0 && m.API != "smtp" {
-		return m.SendAPIMessage(msg)
+	if m.Store != nil {
+		m.listenForQueue(workers)
+		return
 	}
-	return m.SendSMTPMessage(msg)
-}
 
-// SendAPIMessage allows sending of mail directly via API
-// mailgun, sparkpost or sendgrid are supported
-func (m *Mail) SendAPIMessage(msg Message) error {
-	msg = m.sanitizeMessage(msg)
-
-	cfg := mail.Config{
-		URL:         m.APIUrl,
-		APIKey:      m.APIKey,
-		Domain:      m.Domain,
-		FromAddress: msg.From,
-		FromName:    msg.FromName,
+	// messages that are waiting for a retry are not done with, so the workers keep running
+	// until they are
+	work := make(chan delivery)
+	var pending sync.WaitGroup
+	go func() {
+		for msg := range m.Jobs {
+			if msg.ID == "" {
+				msg.ID = token.UUIDv7()
+			}
+			pending.Add(1)
+			work <- delivery{msg: msg}
+		}
+		pending.Wait()
+		close(work)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range work {
+				res, wait, done := m.deliver(d)
+				if !done {
+					// the worker sends other messages during the wait
+					d.attempts = res.Attempts
+					time.AfterFunc(wait, func() { work <- d })
+					continue
+				}
+				m.report(res)
+				pending.Done()
+			}
+		}()
 	}
 
-	switch m.API {
-	case "mailgun":
-		return m.sendUsingMailGun(msg, cfg)
-	case "sparkpost":
-		return m.sendUsingSparkPost(msg, cfg)
-	case "sendgrid":
-		return m.sendUsingSendGrid(msg, cfg)
-	default:
-		return fmt.Errorf("unknown email api %s, only mailgun, sparkpost or sendgrid accepted", m.API)
-	}
+	wg.Wait()
 }
 
-func (m *Mail) sendUsingMailGun(msg Message, cfg mail.Config) error {
-	mailer, err := drivers.NewMailgun(cfg)
-	err = m.sendMailerMessage(msg, mailer)
+// Send allows sending of mail directly, with Driver, or the driver of API when Driver is not set
+func (m *Mail) Send(msg Message) error {
+	driver, err := m.driver()
 	if err != nil {
 		return err
 	}
-	return nil
 
-}
-
-func (m *Mail) sendUsingSparkPost(msg Message, cfg mail.Config) error {
-	mailer, err := drivers.NewSparkPost(cfg)
-	err = m.sendMailerMessage(msg, mailer)
+	email, err := m.render(msg)
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func (m *Mail) sendUsingSendGrid(msg Message, cfg mail.Config) error {
-	mailer, err := drivers.NewSendGrid(cfg)
-	err = m.sendMailerMessage(msg, mailer)
-	if err != nil {
-		return err
-	}
-	return nil
+	return driver.Send(email)
 }
 
-func (m *Mail) sendMailerMessage(msg Message, mailer mail.Mailer) error {
-	formattedMessage, err := m.buildHTMLMessage(msg)
-	if err != nil {
-		return err
-	}
-
-	textMessage, err := m.buildPlainTextMessage(msg)
-	if err != nil {
-		return err
-	}
-
-	tx := &mail.Transmission{
-		Recipients: []string{msg.To},
-		Subject:    msg.Subject,
-		HTML:       formattedMessage,
-		PlainText:  textMessage,
+// SendAPIMessage allows sending of mail directly via the API driver named by API, such as
+// mailgun, sendgrid, postmark or ses
+func (m *Mail) SendAPIMessage(msg Message) error {
+	if m.API == "" || m.API == "smtp" {
+		return Permanent(fmt.Errorf("no email api set, use one of %s", strings.Join(Drivers(), ", ")))
 	}
 
-	err = m.addAPIAttachments(msg, tx)
+	driver, err := m.driver()
 	if err != nil {
 		return err
 	}
 
-	_, err = mailer.Send(tx)
+	email, err := m.render(msg)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return driver.Send(email)
 }
 
-func (m *Mail) addAPIAttachments(msg Message, tx *mail.Transmission) error {
-	if len(msg.Attachments) > 0 {
-		var attachments []mail.Attachment
-
-		for _, x := range msg.Attachments {
-			var attach mail.Attachment
-			content, err := os.ReadFile(x)
-			if err != nil {
-				return err
-			}
-
-			fileName := filepath.Base(x)
-			attach.Bytes = content
-			attach.Filename = fileName
-			attachments = append(attachments, attach)
-		}
-
-		tx.Attachments = attachments
-	}
-
-	return nil
-}
-
-// SendSMTPMessage builds and sends an email using SMTP. It is called by ListenForMail
-// and can also be called directly
+// SendSMTPMessage builds and sends an email using SMTP. It can be called directly to use SMTP
+// whatever the Driver is
 func (m *Mail) SendSMTPMessage(msg Message) error {
-	msg = m.sanitizeMessage(msg)
-	formattedMessage, err := m.buildHTMLMessage(msg)
+	email, err := m.render(msg)
 	if err != nil {
 		return err
 	}
 
-	textMessage, err := m.buildPlainTextMessage(msg)
-	if err != nil {
-		return err
-	}
-
-	server := smtpmail.NewSMTPClient()
-	server.Host = m.Host
-	server.Port = m.Port
-	server.Username = m.Username
-	server.Password = m.Password
-	server.Encryption = m.getEncryption(m.Encryption)
-	server.KeepAlive = false
-	server.ConnectTimeout = 10 * time.Second
-	server.SendTimeout = 10 * time.Second
-
-	smtpClient, err := server.Connect()
-	if err != nil {
-		return err
-	}
-
-	email := smtpmail.NewMSG()
-	email.SetFrom(msg.From).
-		AddTo(msg.To).
-		SetSubject(msg.Subject)
-
-	email.SetBody(smtpmail.TextHTML, formattedMessage)
-	email.AddAlternative(smtpmail.TextPlain, textMessage)
-
-	if len(msg.Attachments) > 0 {
-		for _, x := range msg.Attachments {
-			email.AddAttachment(x)
-		}
-	}
-
-	err = email.Send(smtpClient)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return (&smtpDriver{mail: m}).Send(email)
 }
 
 func (m *Mail) sanitizeMessage(msg Message) Message {
@@ -241,30 +192,17 @@ func (m *Mail) sanitizeMessage(msg Message) Message {
 	return msg
 }
 
-func (m *Mail) getEncryption(e string) smtpmail.Encryption {
-	switch e {
-	case "tls":
-		return smtpmail.EncryptionSTARTTLS
-	case "ssl":
-		return smtpmail.EncryptionSSL
-	case "none":
-		return smtpmail.EncryptionNone
-	default:
-		return smtpmail.EncryptionSTARTTLS
-	}
-}
-
 func (m *Mail) buildHTMLMessage(msg Message) (string, error) {
 	templateToRender := fmt.Sprintf("%s/%s.html.tmpl", m.Templates, msg.Template)
 
 	t, err := template.New("email-html").ParseFiles(templateToRender)
 	if err != nil {
-		return "", err
+		return "", Permanent(err)
 	}
 
 	var tpl bytes.Buffer
 	if err = t.ExecuteTemplate(&tpl, "body", msg.Data); err != nil {
-		return "", err
+		return "", Permanent(err)
 	}
 
 	formattedMessage := tpl.String()
@@ -281,12 +219,12 @@ func (m *Mail) buildPlainTextMessage(msg Message) (string, error) {
 
 	t, err := template.New("email-text").ParseFiles(templateToRender)
 	if err != nil {
-		return "", err
+		return "", Permanent(err)
 	}
 
 	var tpl bytes.Buffer
 	if err = t.ExecuteTemplate(&tpl, "body", msg.Data); err != nil {
-		return "", err
+		return "", Permanent(err)
 	}
 
 	textMessage := tpl.String()
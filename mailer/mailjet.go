@@ -0,0 +1,131 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+func init() {
+	RegisterDriver("mailjet", func(m *Mail) (Driver, error) {
+		apiKey, secretKey, ok := strings.Cut(m.APIKey, ":")
+		if !ok || apiKey == "" || secretKey == "" {
+			return nil, errors.New("mailjet needs MAILER_KEY set to apikey:secretkey")
+		}
+		return &MailjetDriver{APIKey: apiKey, SecretKey: secretKey, URL: m.APIUrl}, nil
+	})
+}
+
+// MailjetDriver sends messages with the Mailjet Send API v3.1
+type MailjetDriver struct {
+	APIKey    string
+	SecretKey string
+	// URL is the API URL, https://api.mailjet.com when not set
+	URL string
+	// Client sends the requests, a client with a 30 second timeout when not set
+	Client *http.Client
+}
+
+type mailjetAddress struct {
+	Email string
+	Name  string `json:",omitempty"`
+}
+
+type mailjetAttachment struct {
+	ContentType   string
+	Filename      string
+	Base64Content string
+}
+
+type mailjetMessage struct {
+	From        mailjetAddress
+	To          []mailjetAddress    `json:",omitempty"`
+	Cc          []mailjetAddress    `json:",omitempty"`
+	Bcc         []mailjetAddress    `json:",omitempty"`
+	ReplyTo     *mailjetAddress     `json:",omitempty"`
+	Subject     string              `json:",omitempty"`
+	TextPart    string              `json:",omitempty"`
+	HTMLPart    string              `json:",omitempty"`
+	Headers     map[string]string   `json:",omitempty"`
+	Attachments []mailjetAttachment `json:",omitempty"`
+	CustomID    string              `json:",omitempty"`
+}
+
+type mailjetResponse struct {
+	Messages []struct {
+		Status string
+		Errors []struct {
+			ErrorMessage string
+		}
+	}
+}
+
+func mailjetAddresses(addresses []*mail.Address) []mailjetAddress {
+	var result []mailjetAddress
+	for _, a := range addresses {
+		result = append(result, mailjetAddress{Email: a.Address, Name: a.Name})
+	}
+	return result
+}
+
+func (d *MailjetDriver) Send(email *Email) error {
+	msg := mailjetMessage{
+		From:     mailjetAddress{Email: email.From.Address, Name: email.From.Name},
+		To:       mailjetAddresses(email.To),
+		Cc:       mailjetAddresses(email.Cc),
+		Bcc:      mailjetAddresses(email.Bcc),
+		Subject:  email.Subject,
+		TextPart: email.Text,
+		HTMLPart: email.HTML,
+		Headers:  email.Headers,
+		CustomID: email.ID,
+	}
+	if email.ReplyTo != nil {
+		msg.ReplyTo = &mailjetAddress{Email: email.ReplyTo.Address, Name: email.ReplyTo.Name}
+	}
+
+	attachments, err := readAttachments(email.Attachments)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		msg.Attachments = append(msg.Attachments, mailjetAttachment{
+			ContentType:   a.ContentType,
+			Filename:      a.Name,
+			Base64Content: base64.StdEncoding.EncodeToString(a.Content),
+		})
+	}
+
+	url := d.URL
+	if url == "" {
+		url = "https://api.mailjet.com"
+	}
+
+	req, _, err := newJSONRequest(strings.TrimSuffix(url, "/")+"/v3.1/send", map[string]interface{}{
+		"Messages": []mailjetMessage{msg},
+	})
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(d.APIKey, d.SecretKey)
+
+	var resp mailjetResponse
+	if err := doJSON("mailjet", d.Client, req, &resp); err != nil {
+		return err
+	}
+
+	for _, m := range resp.Messages {
+		if m.Status != "success" {
+			var messages []string
+			for _, e := range m.Errors {
+				messages = append(messages, e.ErrorMessage)
+			}
+			return Permanent(fmt.Errorf("mailjet: %s: %s", m.Status, strings.Join(messages, "; ")))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMailjetDriver(t *testing.T) {
+	var received struct {
+		Messages []mailjetMessage
+	}
+	var user, password string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3.1/send" {
+			http.NotFound(w, r)
+			return
+		}
+		user, password, _ = r.BasicAuth()
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte(`{"Messages":[{"Status":"success"}]}`))
+	}))
+	defer srv.Close()
+
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", API: "mailjet", APIKey: "key:secret", APIUrl: srv.URL}
+	if err := m.Send(apiMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	if user != "key" || password != "secret" {
+		t.Errorf("expected basic auth with the api and secret keys, got %s:%s", user, password)
+	}
+	if len(received.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %+v", received)
+	}
+
+	msg := received.Messages[0]
+	if msg.From.Email != "me@here.com" || msg.From.Name != "Joe" || len(msg.To) != 2 || msg.To[0].Name != "You" ||
+		len(msg.Cc) != 1 || len(msg.Bcc) != 1 || msg.ReplyTo.Email != "support@here.com" {
+		t.Errorf("unexpected addresses %+v", msg)
+	}
+	if msg.CustomID != "abc" || msg.Headers["List-Unsubscribe"] == "" || len(msg.Attachments) != 1 {
+		t.Errorf("unexpected message %+v", msg)
+	}
+}
+
+func TestMailjetDriver_errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"Messages":[{"Status":"error","Errors":[{"ErrorMessage":"Invalid recipient"}]}]}`))
+	}))
+	defer srv.Close()
+
+	driver := &MailjetDriver{APIKey: "key", SecretKey: "secret", URL: srv.URL}
+	email, err := newEmail(Message{From: "me@here.com", To: "you@there.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := driver.Send(email); err == nil || !IsPermanent(err) {
+		t.Errorf("expected a permanent error for a rejected message, got %v", err)
+	}
+
+	m := Mail{API: "mailjet", APIKey: "key"}
+	if _, err := m.driver(); err == nil {
+		t.Error("expected an error without a secret key")
+	}
+}
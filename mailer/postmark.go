@@ -0,0 +1,128 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+func init() {
+	RegisterDriver("postmark", func(m *Mail) (Driver, error) {
+		if m.APIKey == "" {
+			return nil, errors.New("postmark needs MAILER_KEY set to a server token")
+		}
+		return &PostmarkDriver{ServerToken: m.APIKey, URL: m.APIUrl}, nil
+	})
+}
+
+// PostmarkDriver sends messages with the Postmark email API
+type PostmarkDriver struct {
+	// ServerToken is the API token of the Postmark server
+	ServerToken string
+	// URL is the API URL, https://api.postmarkapp.com when not set
+	URL string
+	// MessageStream is the stream messages are sent to, outbound when not set
+	MessageStream string
+	// Client sends the requests, a client with a 30 second timeout when not set
+	Client *http.Client
+}
+
+type postmarkHeader struct {
+	Name  string
+	Value string
+}
+
+type postmarkAttachment struct {
+	Name        string
+	Content     string
+	ContentType string
+}
+
+type postmarkMessage struct {
+	From          string
+	To            string
+	Cc            string               `json:",omitempty"`
+	Bcc           string               `json:",omitempty"`
+	ReplyTo       string               `json:",omitempty"`
+	Subject       string               `json:",omitempty"`
+	HtmlBody      string               `json:",omitempty"`
+	TextBody      string               `json:",omitempty"`
+	Headers       []postmarkHeader     `json:",omitempty"`
+	Attachments   []postmarkAttachment `json:",omitempty"`
+	MessageStream string
+}
+
+type postmarkResponse struct {
+	ErrorCode int
+	Message   string
+	MessageID string
+}
+
+func (d *PostmarkDriver) Send(email *Email) error {
+	msg := postmarkMessage{
+		From:          email.From.String(),
+		To:            strings.Join(formatted(email.To), ", "),
+		Cc:            strings.Join(formatted(email.Cc), ", "),
+		Bcc:           strings.Join(formatted(email.Bcc), ", "),
+		Subject:       email.Subject,
+		HtmlBody:      email.HTML,
+		TextBody:      email.Text,
+		MessageStream: d.MessageStream,
+	}
+	if msg.MessageStream == "" {
+		msg.MessageStream = "outbound"
+	}
+	if email.ReplyTo != nil {
+		msg.ReplyTo = email.ReplyTo.String()
+	}
+
+	for _, name := range sortedHeaders(email.Headers) {
+		msg.Headers = append(msg.Headers, postmarkHeader{Name: name, Value: email.Headers[name]})
+	}
+
+	attachments, err := readAttachments(email.Attachments)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		msg.Attachments = append(msg.Attachments, postmarkAttachment{
+			Name:        a.Name,
+			Content:     base64.StdEncoding.EncodeToString(a.Content),
+			ContentType: a.ContentType,
+		})
+	}
+
+	url := d.URL
+	if url == "" {
+		url = "https://api.postmarkapp.com"
+	}
+
+	req, _, err := newJSONRequest(strings.TrimSuffix(url, "/")+"/email", msg)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Postmark-Server-Token", d.ServerToken)
+
+	var resp postmarkResponse
+	if err := doJSON("postmark", d.Client, req, &resp); err != nil {
+		return err
+	}
+	if resp.ErrorCode != 0 {
+		return Permanent(fmt.Errorf("postmark: error %d: %s", resp.ErrorCode, resp.Message))
+	}
+
+	return nil
+}
+
+// sortedHeaders returns the names of headers, sorted so requests are the same every time
+func sortedHeaders(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
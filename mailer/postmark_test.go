@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// apiMessage is sent by the tests of the API drivers
+var apiMessage = Message{
+	ID:          "abc",
+	FromName:    "Joe",
+	To:          "You <you@there.com>, them@there.com",
+	Cc:          []string{"cc@there.com"},
+	Bcc:         []string{"bcc@there.com"},
+	ReplyTo:     "support@here.com",
+	Subject:     "test",
+	Template:    "test",
+	Headers:     map[string]string{"List-Unsubscribe": "<https://here.com/unsubscribe>"},
+	Attachments: []string{"./testdata/mail/test.text.tmpl"},
+}
+
+func TestPostmarkDriver(t *testing.T) {
+	var received postmarkMessage
+	var token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/email" {
+			http.NotFound(w, r)
+			return
+		}
+		token = r.Header.Get("X-Postmark-Server-Token")
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte(`{"ErrorCode":0,"Message":"OK","MessageID":"1"}`))
+	}))
+	defer srv.Close()
+
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", API: "postmark", APIKey: "token", APIUrl: srv.URL}
+	if err := m.Send(apiMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "token" {
+		t.Errorf("expected the server token, got %q", token)
+	}
+	if received.From != `"Joe" <me@here.com>` || received.To != `"You" <you@there.com>, <them@there.com>` ||
+		received.Cc != "<cc@there.com>" || received.Bcc != "<bcc@there.com>" || received.ReplyTo != "<support@here.com>" {
+		t.Errorf("unexpected addresses %+v", received)
+	}
+	if received.MessageStream != "outbound" || received.TextBody == "" || received.HtmlBody == "" {
+		t.Errorf("unexpected message %+v", received)
+	}
+	if len(received.Headers) != 1 || received.Headers[0].Name != "List-Unsubscribe" {
+		t.Errorf("unexpected headers %+v", received.Headers)
+	}
+	if len(received.Attachments) != 1 || received.Attachments[0].Name != "test.text.tmpl" {
+		t.Errorf("unexpected attachments %+v", received.Attachments)
+	}
+}
+
+func TestPostmarkDriver_errors(t *testing.T) {
+	status := http.StatusUnprocessableEntity
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"ErrorCode":300,"Message":"Invalid email request"}`))
+	}))
+	defer srv.Close()
+
+	driver := &PostmarkDriver{ServerToken: "token", URL: srv.URL}
+	email, err := newEmail(Message{From: "me@here.com", To: "you@there.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := driver.Send(email); err == nil || !IsPermanent(err) {
+		t.Errorf("expected a permanent error for a rejected message, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := driver.Send(email); err == nil || IsPermanent(err) {
+		t.Errorf("expected a transient error when the API is unavailable, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Preview is a template rendered the way it is sent, with the error of each part
+type Preview struct {
+	HTML      string
+	Text      string
+	HTMLError error
+	TextError error
+}
+
+// TemplateNames returns the names of the templates in the Templates directory, sorted. A name is
+// what a Message sets as Template, e.g. welcome for welcome.html.tmpl and welcome.text.tmpl
+func (m *Mail) TemplateNames() ([]string, error) {
+	entries, err := os.ReadDir(m.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name, ok := strings.CutSuffix(entry.Name(), ".html.tmpl")
+		if !ok {
+			name, ok = strings.CutSuffix(entry.Name(), ".text.tmpl")
+		}
+		if ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// SampleData reads the data a template is previewed with from <name>.json in the Templates
+// directory. The data is nil when the template has no such file
+func (m *Mail) SampleData(name string) (interface{}, error) {
+	path := filepath.Join(m.Templates, name+".json")
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// Preview renders the html and text templates of name with data, without sending anything. The
+// html is inlined by premailer, as it is when a message is sent
+func (m *Mail) Preview(name string, data interface{}) Preview {
+	msg := Message{Template: name, Data: data}
+
+	var p Preview
+	p.HTML, p.HTMLError = m.buildHTMLMessage(msg)
+	p.Text, p.TextError = m.buildPlainTextMessage(msg)
+	return p
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMail_TemplateNames(t *testing.T) {
+	m := Mail{Templates: "./testdata/mail"}
+
+	names, err := m.TemplateNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(names) != "[broken preview test]" {
+		t.Errorf("expected [broken preview test], got %v", names)
+	}
+}
+
+func TestMail_SampleData(t *testing.T) {
+	m := Mail{Templates: "./testdata/mail"}
+
+	data, err := m.SampleData("preview")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(data) != "map[Items:[one two] Name:Joe]" {
+		t.Errorf("unexpected sample data %v", data)
+	}
+
+	data, err = m.SampleData("test")
+	if err != nil || data != nil {
+		t.Errorf("expected no data for a template without a json file, got %v, %v", data, err)
+	}
+}
+
+func TestMail_Preview(t *testing.T) {
+	m := Mail{Templates: "./testdata/mail"}
+	data, _ := m.SampleData("preview")
+
+	p := m.Preview("preview", data)
+	if p.HTMLError != nil || p.TextError != nil {
+		t.Fatal(p.HTMLError, p.TextError)
+	}
+	if !strings.Contains(p.HTML, `<p style="color:#333333">Hello Joe, you have 2 items</p>`) {
+		t.Errorf("expected the inlined html, got %s", p.HTML)
+	}
+	if !strings.Contains(p.Text, "Hello Joe, you have 2 items") {
+		t.Errorf("expected the text, got %s", p.Text)
+	}
+
+	p = m.Preview("broken", nil)
+	if p.HTMLError == nil || !strings.Contains(p.HTMLError.Error(), "missing") {
+		t.Errorf("expected the html error, got %v", p.HTMLError)
+	}
+	if p.TextError == nil {
+		t.Error("expected an error for the missing text template")
+	}
+}
@@ -0,0 +1,182 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoMessage is returned by QueueStore.Reserve when no message is due
+var ErrNoMessage = errors.New("mailer: no message is due")
+
+// payloadError is a stored message that can't be read, e.g. as it was changed by hand. Stores
+// bury such messages when they reserve them, so they don't hold up the messages after them
+type payloadError struct {
+	id  string
+	err error
+}
+
+func (e *payloadError) Error() string {
+	return fmt.Sprintf("mailer: queued message %s can't be read: %v", e.id, e.err)
+}
+
+func (e *payloadError) Unwrap() error {
+	return e.err
+}
+
+// QueuedMessage is a message in a QueueStore
+type QueuedMessage struct {
+	Message Message `json:"message"`
+	// State is StateQueued for messages waiting to be sent, or StateFailed for dead letters
+	State     State  `json:"state"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// AvailableAt is when the message can be reserved: when it is due, or when the reservation
+	// of a worker that is sending it times out
+	AvailableAt time.Time `json:"available_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// QueueStore keeps queued messages outside the process, so they survive restarts. Messages are
+// delivered at least once: a reserved message that is not completed, released or buried before
+// its visibility timeout is reserved again, e.g. when the instance sending it crashed. Message data
+// is stored as JSON, so templates receive it as a map when a struct was queued
+type QueueStore interface {
+	// Push adds a message that is due now
+	Push(ctx context.Context, msg Message) error
+	// Reserve claims the message that has been due the longest for visibility, and counts an
+	// attempt. It returns ErrNoMessage when no message is due
+	Reserve(ctx context.Context, visibility time.Duration) (*QueuedMessage, error)
+	// Complete removes a message that was sent
+	Complete(ctx context.Context, id string) error
+	// Release makes a reserved message due again at a later time, to retry it
+	Release(ctx context.Context, id, lastError string, at time.Time) error
+	// Bury marks a message as failed, after which it is only retried by Retry
+	Bury(ctx context.Context, id, lastError string) error
+	// List returns the messages in a state, or all messages when state is empty
+	List(ctx context.Context, state State) ([]QueuedMessage, error)
+	// Retry makes a failed message due again, or all failed messages when id is empty
+	Retry(ctx context.Context, id string) (int, error)
+	// Purge removes the messages in a state, or all messages when state is empty
+	Purge(ctx context.Context, state State) (int, error)
+}
+
+const (
+	// defaultVisibility is how long a reserved message is hidden from other workers
+	defaultVisibility = 5 * time.Minute
+	// pollInterval is how long workers wait when no message is due
+	pollInterval = time.Second
+	// maxReserveBackoff is the longest workers wait when the store keeps failing
+	maxReserveBackoff = time.Minute
+)
+
+// listenForQueue moves messages sent on Jobs to the Store, and runs the workers that send the
+// messages in the Store, until Jobs is closed and the workers finished the messages they reserved
+func (m *Mail) listenForQueue(workers int) {
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.queueWorker(done)
+		}()
+	}
+
+	for msg := range m.Jobs {
+		if _, err := m.Queue(msg); err != nil {
+			m.report(Result{Success: false, Error: err, ID: msg.ID})
+		}
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+func (m *Mail) queueWorker(done <-chan struct{}) {
+	ctx := context.Background()
+
+	visibility := m.Visibility
+	if visibility <= 0 {
+		visibility = defaultVisibility
+	}
+
+	failures := 0
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		qm, err := m.Store.Reserve(ctx, visibility)
+		if err != nil {
+			wait := pollInterval
+			if errors.Is(err, ErrNoMessage) {
+				failures = 0
+			} else {
+				// the store is unavailable, which is reported, and asked less often until it recovers
+				failures++
+				wait = reserveBackoff(failures)
+				m.report(Result{Success: false, Error: fmt.Errorf("mailer: reserving a queued message: %w", err)})
+			}
+
+			select {
+			case <-done:
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+		failures = 0
+
+		if res, finished := m.deliverQueued(ctx, qm); finished {
+			m.report(res)
+		}
+	}
+}
+
+// reserveBackoff is how long a worker waits after Reserve failed a number of times in a row,
+// which doubles with every failure up to maxReserveBackoff
+func reserveBackoff(failures int) time.Duration {
+	wait := pollInterval
+	for i := 1; i < failures && wait < maxReserveBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxReserveBackoff)
+}
+
+// deliverQueued makes one attempt to send a reserved message. Transient failures are released
+// to be retried after the backoff, until MaxAttempts is reached and the message is buried
+func (m *Mail) deliverQueued(ctx context.Context, qm *QueuedMessage) (Result, bool) {
+	msg := qm.Message
+	s := m.tracker()
+	s.set(msg.ID, StateSending, qm.Attempts, nil)
+
+	err := m.Send(msg)
+	if err == nil {
+		s.set(msg.ID, StateSent, qm.Attempts, nil)
+		if err := m.Store.Complete(ctx, msg.ID); err != nil {
+			// the message will be sent again when its reservation times out
+			return Result{Success: true, Error: err, ID: msg.ID, Attempts: qm.Attempts}, true
+		}
+		return Result{Success: true, ID: msg.ID, Attempts: qm.Attempts}, true
+	}
+
+	if !IsPermanent(err) && qm.Attempts < m.MaxAttempts {
+		s.set(msg.ID, StateRetrying, qm.Attempts, err)
+		_ = m.Store.Release(ctx, msg.ID, err.Error(), time.Now().Add(m.backoff(qm.Attempts)))
+		return Result{}, false
+	}
+
+	s.set(msg.ID, StateFailed, qm.Attempts, err)
+	_ = m.Store.Bury(ctx, msg.ID, err.Error())
+	if m.DeadLetters != nil {
+		_ = m.DeadLetters.Add(DeadLetter{Message: msg, Error: err.Error(), Attempts: qm.Attempts, FailedAt: time.Now()})
+	}
+
+	return Result{Success: false, Error: err, ID: msg.ID, Attempts: qm.Attempts}, true
+}
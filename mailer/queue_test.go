@@ -0,0 +1,308 @@
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/dgraph-io/badger/v4"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBadgerQueue(t *testing.T) *BadgerQueue {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	return NewBadgerQueue(db)
+}
+
+func TestBadgerQueue(t *testing.T) {
+	ctx := context.Background()
+	q := newTestBadgerQueue(t)
+
+	_ = q.Push(ctx, Message{ID: "1", To: "you@there.com", Data: map[string]interface{}{"name": "you"}})
+	_ = q.Push(ctx, Message{ID: "2", To: "them@there.com"})
+
+	qm, err := q.Reserve(ctx, time.Minute)
+	if err != nil || qm.Message.ID != "1" || qm.Attempts != 1 || qm.Message.Data.(map[string]interface{})["name"] != "you" {
+		t.Fatalf("expected to reserve message 1, got %+v, %v", qm, err)
+	}
+
+	// a reserved message is hidden until its visibility timeout
+	qm, err = q.Reserve(ctx, time.Minute)
+	if err != nil || qm.Message.ID != "2" {
+		t.Fatalf("expected to reserve message 2, got %+v, %v", qm, err)
+	}
+	if _, err = q.Reserve(ctx, time.Minute); !errors.Is(err, ErrNoMessage) {
+		t.Errorf("expected no message to be due, got %v", err)
+	}
+
+	// a released message is due again at the given time
+	_ = q.Release(ctx, "1", "try again", time.Now())
+	qm, err = q.Reserve(ctx, -time.Second)
+	if err != nil || qm.Message.ID != "1" || qm.Attempts != 2 || qm.LastError != "try again" {
+		t.Fatalf("expected to reserve message 1 again, got %+v, %v", qm, err)
+	}
+
+	// a reservation that timed out is reserved again
+	qm, err = q.Reserve(ctx, time.Minute)
+	if err != nil || qm.Message.ID != "1" || qm.Attempts != 3 {
+		t.Fatalf("expected message 1 after its visibility timeout, got %+v, %v", qm, err)
+	}
+
+	_ = q.Complete(ctx, "2")
+	_ = q.Bury(ctx, "1", "rejected")
+
+	failed, _ := q.List(ctx, StateFailed)
+	if len(failed) != 1 || failed[0].Message.ID != "1" || failed[0].LastError != "rejected" {
+		t.Errorf("expected message 1 to have failed, got %+v", failed)
+	}
+	if all, _ := q.List(ctx, ""); len(all) != 1 {
+		t.Errorf("expected only message 1 to be left, got %+v", all)
+	}
+
+	if n, err := q.Retry(ctx, ""); n != 1 || err != nil {
+		t.Errorf("expected to retry 1 message, got %d, %v", n, err)
+	}
+	qm, err = q.Reserve(ctx, time.Minute)
+	if err != nil || qm.Message.ID != "1" || qm.Attempts != 1 {
+		t.Fatalf("expected the retried message with its attempts reset, got %+v, %v", qm, err)
+	}
+
+	if n, err := q.Purge(ctx, StateFailed); n != 0 || err != nil {
+		t.Errorf("expected to purge no failed messages, got %d, %v", n, err)
+	}
+	if n, err := q.Purge(ctx, ""); n != 1 || err != nil {
+		t.Errorf("expected to purge 1 message, got %d, %v", n, err)
+	}
+}
+
+func TestSQLQueue_query(t *testing.T) {
+	postgres := NewSQLQueue(nil, "postgres")
+	if query := postgres.query("update %s set state = ? where id = ?", postgres.table()); query != "update mail_queue set state = $1 where id = $2" {
+		t.Errorf("unexpected postgres query %s", query)
+	}
+
+	mysql := NewSQLQueue(nil, "mysql")
+	if query := mysql.query("update %s set state = ? where id = ?", mysql.table()); query != "update mail_queue set state = ? where id = ?" {
+		t.Errorf("unexpected mysql query %s", query)
+	}
+}
+
+func TestSQLQueue_Reserve_badPayload(t *testing.T) {
+	now := time.Now().UnixMilli()
+	db := &queueDB{selects: [][]driver.Value{
+		{"bad", "{not json", string(StateQueued), int64(0), "", now, now},
+		{"good", `{"To":"you@there.com"}`, string(StateQueued), int64(0), "", now, now},
+	}}
+	q := NewSQLQueue(sql.OpenDB(db), "postgres")
+	defer q.DB.Close()
+
+	qm, err := q.Reserve(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qm.Message.ID != "good" || qm.Message.To != "you@there.com" || qm.Attempts != 1 {
+		t.Errorf("expected the message after the bad one, got %+v", qm)
+	}
+
+	if len(db.execs) != 2 || !strings.HasPrefix(db.execs[0].query, "update mail_queue set state = $1") ||
+		db.execs[0].args[0] != string(StateFailed) || db.execs[0].args[2] != "bad" {
+		t.Errorf("expected the bad message to be buried, got %+v", db.execs)
+	}
+}
+
+// queueDB is a database that answers selects with the rows in selects, one at a time, and
+// records the other statements
+type queueDB struct {
+	selects [][]driver.Value
+	execs   []queueExec
+}
+
+type queueExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (db *queueDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *queueDB) Driver() driver.Driver                        { return nil }
+func (db *queueDB) Close() error                                 { return nil }
+func (db *queueDB) Begin() (driver.Tx, error)                    { return db, nil }
+func (db *queueDB) Commit() error                                { return nil }
+func (db *queueDB) Rollback() error                              { return nil }
+
+func (db *queueDB) Prepare(query string) (driver.Stmt, error) {
+	return &queueStmt{db, query}, nil
+}
+
+type queueStmt struct {
+	db    *queueDB
+	query string
+}
+
+func (s *queueStmt) Close() error  { return nil }
+func (s *queueStmt) NumInput() int { return -1 }
+
+func (s *queueStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.execs = append(s.db.execs, queueExec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *queueStmt) Query([]driver.Value) (driver.Rows, error) {
+	rows := &queueRows{}
+	if len(s.db.selects) > 0 {
+		rows.row, s.db.selects = s.db.selects[0], s.db.selects[1:]
+	}
+	return rows, nil
+}
+
+type queueRows struct {
+	row []driver.Value
+}
+
+func (r *queueRows) Columns() []string {
+	return strings.Split(sqlQueueColumns, ", ")
+}
+
+func (r *queueRows) Close() error { return nil }
+
+func (r *queueRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+// TestMail_ListenForMail_store sends to a port nobody listens on, so the message is released
+// for retries until it is buried
+func TestMail_ListenForMail_store(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	q := newTestBadgerQueue(t)
+	m := Mail{
+		Templates:   "./testdata/mail",
+		Host:        "127.0.0.1",
+		Port:        port,
+		Encryption:  "none",
+		FromAddress: "me@here.com",
+		Jobs:        make(chan Message),
+		MaxAttempts: 2,
+		Backoff:     10 * time.Millisecond,
+		Store:       q,
+	}
+
+	results := make(chan Result, 1)
+	m.OnResult = func(res Result) { results <- res }
+	stopped := make(chan struct{})
+	go func() {
+		m.ListenForMail()
+		close(stopped)
+	}()
+	defer func() {
+		close(m.Jobs)
+		<-stopped
+	}()
+
+	// messages sent on Jobs are added to the store too
+	m.Jobs <- Message{ID: "jobs", To: "you@there.com", Subject: "test", Template: "test"}
+	if res := <-results; res.Success || res.ID != "jobs" || res.Attempts != 2 {
+		t.Errorf("expected 2 failed attempts, got %+v", res)
+	}
+
+	id, err := m.Queue(Message{To: "you@there.com", Subject: "test", Template: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := <-results; res.Success || res.ID != id || res.Attempts != 2 {
+		t.Errorf("expected 2 failed attempts for %s, got %+v", id, res)
+	}
+
+	failed, _ := q.List(context.Background(), StateFailed)
+	if len(failed) != 2 || failed[1].Message.ID != id || failed[1].LastError == "" {
+		t.Errorf("expected both messages to have failed, got %+v", failed)
+	}
+}
+
+// failingStore is a queue store that can't be reached
+type failingStore struct {
+	QueueStore
+	err      error
+	reserves chan struct{}
+}
+
+func (s *failingStore) Reserve(context.Context, time.Duration) (*QueuedMessage, error) {
+	s.reserves <- struct{}{}
+	return nil, s.err
+}
+
+func TestMail_queueWorker_reserveError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		reported bool
+	}{
+		{"no message", ErrNoMessage, false},
+		{"wrapped no message", fmt.Errorf("redis: %w", ErrNoMessage), false},
+		{"store unavailable", errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &failingStore{err: tt.err, reserves: make(chan struct{}, 1)}
+			results := make(chan Result, 1)
+			m := Mail{Store: store, OnResult: func(res Result) { results <- res }}
+
+			done := make(chan struct{})
+			stopped := make(chan struct{})
+			go func() {
+				m.queueWorker(done)
+				close(stopped)
+			}()
+
+			<-store.reserves
+			close(done)
+			<-stopped
+
+			select {
+			case res := <-results:
+				if !tt.reported || res.Success || !errors.Is(res.Error, tt.err) {
+					t.Errorf("unexpected result %+v", res)
+				}
+			default:
+				if tt.reported {
+					t.Error("expected the error to be reported")
+				}
+			}
+		})
+	}
+}
+
+func TestReserveBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := reserveBackoff(tt.failures); got != tt.want {
+			t.Errorf("%d failures: expected %s, got %s", tt.failures, tt.want, got)
+		}
+	}
+}
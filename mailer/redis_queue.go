@@ -0,0 +1,273 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+// RedisQueue keeps queued messages in Redis, so any number of instances can share the queue.
+// Queued messages are in a sorted set scored by the time they are available, failed messages
+// in a sorted set scored by the time they failed, and every message is a hash
+type RedisQueue struct {
+	Client *redis.Client
+	// Prefix is prepended to the keys, usually REDIS_PREFIX
+	Prefix string
+}
+
+// NewRedisQueue returns a queue in Redis
+func NewRedisQueue(client *redis.Client, prefix string) *RedisQueue {
+	return &RedisQueue{Client: client, Prefix: prefix}
+}
+
+func (q *RedisQueue) queuedKey() string {
+	return q.Prefix + "mailqueue:queued"
+}
+
+func (q *RedisQueue) failedKey() string {
+	return q.Prefix + "mailqueue:failed"
+}
+
+func (q *RedisQueue) messagePrefix() string {
+	return q.Prefix + "mailqueue:msg:"
+}
+
+func (q *RedisQueue) setKey(state State) (string, error) {
+	switch state {
+	case StateQueued:
+		return q.queuedKey(), nil
+	case StateFailed:
+		return q.failedKey(), nil
+	}
+	return "", fmt.Errorf("mailer: queued messages are %s or %s, not %s", StateQueued, StateFailed, state)
+}
+
+// reserveScript moves the message that has been due the longest to the end of its visibility
+// timeout and counts an attempt, atomically, so no two instances reserve the same message
+var reserveScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call('ZADD', KEYS[1], ARGV[2], ids[1])
+redis.call('HINCRBY', ARGV[3] .. ids[1], 'attempts', 1)
+return ids[1]
+`)
+
+// Push adds a message that is due now
+func (q *RedisQueue) Push(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	now := time.Now().UnixMilli()
+	_, err = q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.messagePrefix()+msg.ID, "payload", payload, "state", string(StateQueued),
+			"attempts", 0, "last_error", "", "created_at", now)
+		pipe.ZAdd(ctx, q.queuedKey(), redis.Z{Score: float64(now), Member: msg.ID})
+		return nil
+	})
+	return err
+}
+
+// Reserve claims the message that has been due the longest for visibility. Messages that can't
+// be read are buried
+func (q *RedisQueue) Reserve(ctx context.Context, visibility time.Duration) (*QueuedMessage, error) {
+	for {
+		now := time.Now()
+		until := now.Add(visibility)
+
+		id, err := reserveScript.Run(ctx, q.Client, []string{q.queuedKey()},
+			now.UnixMilli(), until.UnixMilli(), q.messagePrefix()).Text()
+		if err == redis.Nil {
+			return nil, ErrNoMessage
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		qm, err := q.get(ctx, id)
+		var payloadErr *payloadError
+		if errors.As(err, &payloadErr) {
+			if err := q.Bury(ctx, id, err.Error()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		qm.AvailableAt = until
+
+		return qm, nil
+	}
+}
+
+func (q *RedisQueue) get(ctx context.Context, id string) (*QueuedMessage, error) {
+	fields, err := q.Client.HGetAll(ctx, q.messagePrefix()+id).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("mailer: queued message %s not found", id)
+	}
+
+	var qm QueuedMessage
+	if err := json.Unmarshal([]byte(fields["payload"]), &qm.Message); err != nil {
+		return nil, &payloadError{id: id, err: err}
+	}
+	qm.Message.ID = id
+	qm.State = State(fields["state"])
+	qm.Attempts, _ = strconv.Atoi(fields["attempts"])
+	qm.LastError = fields["last_error"]
+	createdAt, _ := strconv.ParseInt(fields["created_at"], 10, 64)
+	qm.CreatedAt = time.UnixMilli(createdAt)
+
+	return &qm, nil
+}
+
+// Complete removes a message that was sent
+func (q *RedisQueue) Complete(ctx context.Context, id string) error {
+	_, err := q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, q.queuedKey(), id)
+		pipe.Del(ctx, q.messagePrefix()+id)
+		return nil
+	})
+	return err
+}
+
+// Release makes a reserved message due again at a later time
+func (q *RedisQueue) Release(ctx context.Context, id, lastError string, at time.Time) error {
+	_, err := q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.messagePrefix()+id, "last_error", lastError)
+		pipe.ZAdd(ctx, q.queuedKey(), redis.Z{Score: float64(at.UnixMilli()), Member: id})
+		return nil
+	})
+	return err
+}
+
+// Bury marks a message as failed
+func (q *RedisQueue) Bury(ctx context.Context, id, lastError string) error {
+	_, err := q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.messagePrefix()+id, "state", string(StateFailed), "last_error", lastError)
+		pipe.ZRem(ctx, q.queuedKey(), id)
+		pipe.ZAdd(ctx, q.failedKey(), redis.Z{Score: float64(time.Now().UnixMilli()), Member: id})
+		return nil
+	})
+	return err
+}
+
+// List returns the messages in a state, or all messages when state is empty, in the order they
+// are due or failed
+func (q *RedisQueue) List(ctx context.Context, state State) ([]QueuedMessage, error) {
+	states := []State{StateQueued, StateFailed}
+	if state != "" {
+		states = []State{state}
+	}
+
+	var messages []QueuedMessage
+	for _, s := range states {
+		key, err := q.setKey(s)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := q.Client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, z := range entries {
+			qm, err := q.get(ctx, z.Member.(string))
+			if err != nil {
+				// completed since the range was read
+				continue
+			}
+			if s == StateQueued {
+				qm.AvailableAt = time.UnixMilli(int64(z.Score))
+			}
+			messages = append(messages, *qm)
+		}
+	}
+
+	return messages, nil
+}
+
+// Retry makes a failed message due again, or all failed messages when id is empty
+func (q *RedisQueue) Retry(ctx context.Context, id string) (int, error) {
+	ids := []string{id}
+	if id == "" {
+		var err error
+		ids, err = q.Client.ZRange(ctx, q.failedKey(), 0, -1).Result()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	now := float64(time.Now().UnixMilli())
+	for _, id := range ids {
+		removed, err := q.Client.ZRem(ctx, q.failedKey(), id).Result()
+		if err != nil {
+			return n, err
+		}
+		if removed == 0 {
+			// not failed, or retried by someone else
+			continue
+		}
+
+		_, err = q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, q.messagePrefix()+id, "state", string(StateQueued), "attempts", 0)
+			pipe.ZAdd(ctx, q.queuedKey(), redis.Z{Score: now, Member: id})
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// Purge removes the messages in a state, or all messages when state is empty
+func (q *RedisQueue) Purge(ctx context.Context, state State) (int, error) {
+	states := []State{StateQueued, StateFailed}
+	if state != "" {
+		states = []State{state}
+	}
+
+	n := 0
+	for _, s := range states {
+		key, err := q.setKey(s)
+		if err != nil {
+			return n, err
+		}
+
+		ids, err := q.Client.ZRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return n, err
+		}
+
+		for _, id := range ids {
+			removed, err := q.Client.ZRem(ctx, key, id).Result()
+			if err != nil {
+				return n, err
+			}
+			if removed == 0 {
+				continue
+			}
+			if err := q.Client.Del(ctx, q.messagePrefix()+id).Err(); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
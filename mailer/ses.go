@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterDriver("ses", func(m *Mail) (Driver, error) {
+		accessKeyID, secretAccessKey, ok := strings.Cut(m.APIKey, ":")
+		if !ok || accessKeyID == "" || secretAccessKey == "" {
+			return nil, errors.New("ses needs MAILER_KEY set to accesskeyid:secretaccesskey")
+		}
+		return &SESDriver{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			Region:          sesRegion(m.APIUrl),
+			URL:             m.APIUrl,
+		}, nil
+	})
+}
+
+// SESDriver sends messages with the Amazon SES v2 HTTP API. Messages are sent as raw MIME, so
+// headers and attachments are sent as they are by SMTP
+type SESDriver struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// Region is the AWS region of SES, us-east-1 when not set
+	Region string
+	// URL is the API URL, https://email.<Region>.amazonaws.com when not set
+	URL string
+	// Client sends the requests, a client with a 30 second timeout when not set
+	Client *http.Client
+}
+
+// sesRegion returns the region of an SES URL such as https://email.eu-west-1.amazonaws.com
+func sesRegion(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err == nil {
+		parts := strings.Split(u.Hostname(), ".")
+		if len(parts) == 4 && parts[0] == "email" && parts[2] == "amazonaws" {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+type sesRequest struct {
+	FromEmailAddress string
+	Destination      struct {
+		ToAddresses  []string `json:",omitempty"`
+		CcAddresses  []string `json:",omitempty"`
+		BccAddresses []string `json:",omitempty"`
+	}
+	Content struct {
+		Raw struct {
+			Data string
+		}
+	}
+}
+
+func (d *SESDriver) Send(email *Email) error {
+	msg := mimeMessage(email, false)
+	if msg.Error != nil {
+		return Permanent(msg.Error)
+	}
+
+	var body sesRequest
+	body.FromEmailAddress = email.From.String()
+	body.Destination.ToAddresses = emails(email.To)
+	body.Destination.CcAddresses = emails(email.Cc)
+	body.Destination.BccAddresses = emails(email.Bcc)
+	body.Content.Raw.Data = base64.StdEncoding.EncodeToString([]byte(msg.GetMessage()))
+
+	region := d.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := d.URL
+	if endpoint == "" {
+		endpoint = "https://email." + region + ".amazonaws.com"
+	}
+
+	req, payload, err := newJSONRequest(strings.TrimSuffix(endpoint, "/")+"/v2/email/outbound-emails", body)
+	if err != nil {
+		return err
+	}
+	signV4(req, payload, d.AccessKeyID, d.SecretAccessKey, region, "ses", time.Now())
+
+	return doJSON("ses", d.Client, req, nil)
+}
+
+// signV4 signs a request with AWS Signature Version 4, signing the host, content type and date
+func signV4(req *http.Request, payload []byte, accessKeyID, secretAccessKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host, "x-amz-date": amzDate}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(payload),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
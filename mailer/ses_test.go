@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignV4 uses the get-vanilla case of the AWS Signature Version 4 test suite
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	signV4(req, nil, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("expected %s, got %s", expected, auth)
+	}
+}
+
+func TestSESDriver(t *testing.T) {
+	var received sesRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/email/outbound-emails" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte(`{"MessageId":"1"}`))
+	}))
+	defer srv.Close()
+
+	m := Mail{Templates: "./testdata/mail", FromAddress: "me@here.com", API: "ses", APIKey: "id:secret", APIUrl: srv.URL}
+	if err := m.Send(apiMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=id/") || !strings.Contains(auth, "/us-east-1/ses/aws4_request") {
+		t.Errorf("unexpected authorization %s", auth)
+	}
+	if received.FromEmailAddress != `"Joe" <me@here.com>` || len(received.Destination.ToAddresses) != 2 ||
+		received.Destination.BccAddresses[0] != "bcc@there.com" {
+		t.Errorf("unexpected request %+v", received)
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(received.Content.Raw.Data)
+	for _, expected := range []string{"Reply-To: <support@here.com>", "List-Unsubscribe: <https://here.com/unsubscribe>", "test.text.tmpl"} {
+		if !strings.Contains(string(raw), expected) {
+			t.Errorf("expected %q in the raw message, got %s", expected, raw)
+		}
+	}
+	if strings.Contains(string(raw), "bcc@there.com") {
+		t.Error("expected no Bcc header in the raw message")
+	}
+}
+
+func TestSESRegion(t *testing.T) {
+	if region := sesRegion("https://email.eu-west-1.amazonaws.com"); region != "eu-west-1" {
+		t.Errorf("expected eu-west-1, got %s", region)
+	}
+	if region := sesRegion("http://127.0.0.1:4566"); region != "" {
+		t.Errorf("expected no region, got %s", region)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"log"
+	"net"
+	"net/textproto"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -26,8 +29,22 @@ var mailer = Mail{
 
 func TestMain(m *testing.M) {
 	p, err := dockertest.NewPool("")
+	if err == nil {
+		err = p.Client.Ping()
+	}
 	if err != nil {
-		log.Fatal("could not connect to docker", err)
+		// without docker the tests send to an SMTP server that accepts everything
+		log.Println("docker is not available, using a local SMTP server:", err)
+		l, err := net.Listen("tcp", "127.0.0.1:1026")
+		if err != nil {
+			log.Fatal("could not start the local SMTP server", err)
+		}
+		go serveSMTP(l)
+
+		go mailer.ListenForMail()
+		code := m.Run()
+		_ = l.Close()
+		os.Exit(code)
 	}
 
 	pool = p
@@ -66,3 +83,42 @@ func TestMain(m *testing.M) {
 
 	os.Exit(code)
 }
+
+// serveSMTP accepts every message sent to l, speaking just enough SMTP for the smtp client
+func serveSMTP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+			tp := textproto.NewConn(conn)
+			_ = tp.PrintfLine("220 localhost ESMTP")
+
+			for {
+				line, err := tp.ReadLine()
+				if err != nil {
+					return
+				}
+
+				switch strings.ToUpper(strings.SplitN(line, " ", 2)[0]) {
+				case "EHLO", "HELO":
+					_ = tp.PrintfLine("250 localhost")
+				case "DATA":
+					_ = tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+					if _, err := tp.ReadDotBytes(); err != nil {
+						return
+					}
+					_ = tp.PrintfLine("250 ok")
+				case "QUIT":
+					_ = tp.PrintfLine("221 bye")
+					return
+				default:
+					_ = tp.PrintfLine("250 ok")
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	smtpmail "github.com/xhit/go-simple-mail/v2"
+	"strings"
+	"time"
+)
+
+// smtpDriver sends messages to the SMTP server of a Mail
+type smtpDriver struct {
+	mail *Mail
+}
+
+func (d *smtpDriver) Send(email *Email) error {
+	m := d.mail
+
+	server := smtpmail.NewSMTPClient()
+	server.Host = m.Host
+	server.Port = m.Port
+	server.Username = m.Username
+	server.Password = m.Password
+	server.Encryption = m.getEncryption(m.Encryption)
+	server.KeepAlive = false
+	server.ConnectTimeout = 10 * time.Second
+	server.SendTimeout = 10 * time.Second
+
+	// the message is built first, as sending it again won't fix an address or attachment it
+	// can't use
+	msg := mimeMessage(email, false)
+	if msg.Error != nil {
+		return Permanent(msg.Error)
+	}
+
+	smtpClient, err := server.Connect()
+	if err != nil {
+		return err
+	}
+
+	return msg.Send(smtpClient)
+}
+
+func (m *Mail) getEncryption(e string) smtpmail.Encryption {
+	switch e {
+	case "tls":
+		return smtpmail.EncryptionSTARTTLS
+	case "ssl":
+		return smtpmail.EncryptionSSL
+	case "none":
+		return smtpmail.EncryptionNone
+	default:
+		return smtpmail.EncryptionSTARTTLS
+	}
+}
+
+// mimeMessage builds the RFC 5322 message of an email, with the HTML and the text version as
+// alternatives. Bcc is only a header of the message when bccHeader is set
+func mimeMessage(email *Email, bccHeader bool) *smtpmail.Email {
+	msg := smtpmail.NewMSG()
+	msg.AddBccToHeader = bccHeader
+	msg.SetFrom(email.From.String()).
+		AddTo(formatted(email.To)...).
+		AddCc(formatted(email.Cc)...).
+		AddBcc(formatted(email.Bcc)...).
+		SetSubject(email.Subject)
+
+	if email.ReplyTo != nil {
+		msg.SetReplyTo(email.ReplyTo.String())
+	}
+
+	if email.ID != "" {
+		domain := email.From.Address[strings.LastIndex(email.From.Address, "@")+1:]
+		msg.AddHeader("Message-ID", "<"+email.ID+"@"+domain+">")
+	}
+
+	for name, value := range email.Headers {
+		msg.AddHeader(name, value)
+	}
+
+	msg.SetBody(smtpmail.TextHTML, email.HTML)
+	msg.AddAlternative(smtpmail.TextPlain, email.Text)
+
+	for _, x := range email.Attachments {
+		msg.AddAttachment(x)
+	}
+
+	return msg
+}
@@ -0,0 +1,247 @@
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLQueue keeps queued messages in a database table, created by the migration of
+// `rapidus make mailqueue`. Postgres and MySQL/MariaDB reserve messages with
+// FOR UPDATE SKIP LOCKED, so any number of instances can share the table. Times are stored as
+// Unix milliseconds, so they compare the same in every database and time zone
+type SQLQueue struct {
+	DB *sql.DB
+	// Type is the DATABASE_TYPE, which decides the placeholders and locking used
+	Type string
+	// Table is the name of the table, mail_queue when not set
+	Table string
+}
+
+// NewSQLQueue returns a queue in the mail_queue table
+func NewSQLQueue(db *sql.DB, dbType string) *SQLQueue {
+	return &SQLQueue{DB: db, Type: dbType, Table: "mail_queue"}
+}
+
+const sqlQueueColumns = "id, payload, state, attempts, last_error, available_at, created_at"
+
+func (q *SQLQueue) table() string {
+	if q.Table == "" {
+		return "mail_queue"
+	}
+	return q.Table
+}
+
+// query replaces the ? placeholders of a query with $n for Postgres
+func (q *SQLQueue) query(format string, args ...interface{}) string {
+	query := fmt.Sprintf(format, args...)
+
+	switch q.Type {
+	case "postgres", "postgresql", "pgx":
+	default:
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// skipLocked returns the locking clause that keeps instances from reserving the same message
+func (q *SQLQueue) skipLocked() string {
+	switch q.Type {
+	case "postgres", "postgresql", "pgx", "mysql", "mariadb":
+		return " for update skip locked"
+	}
+	return ""
+}
+
+// Push adds a message that is due now
+func (q *SQLQueue) Push(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	now := time.Now().UnixMilli()
+	_, err = q.DB.ExecContext(ctx, q.query("insert into %s (%s) values (?, ?, ?, 0, '', ?, ?)", q.table(), sqlQueueColumns),
+		msg.ID, string(payload), string(StateQueued), now, now)
+	return err
+}
+
+// Reserve claims the message that has been due the longest for visibility. Messages that can't
+// be read are buried
+func (q *SQLQueue) Reserve(ctx context.Context, visibility time.Duration) (*QueuedMessage, error) {
+	for {
+		qm, err := q.reserve(ctx, visibility)
+		var payloadErr *payloadError
+		if !errors.As(err, &payloadErr) {
+			return qm, err
+		}
+	}
+}
+
+func (q *SQLQueue) reserve(ctx context.Context, visibility time.Duration) (*QueuedMessage, error) {
+	tx, err := q.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	row := tx.QueryRowContext(ctx, q.query("select %s from %s where state = ? and available_at <= ? order by available_at limit 1%s",
+		sqlQueueColumns, q.table(), q.skipLocked()), string(StateQueued), now.UnixMilli())
+
+	qm, err := scanQueuedMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoMessage
+	}
+	var payloadErr *payloadError
+	if errors.As(err, &payloadErr) {
+		_, buryErr := tx.ExecContext(ctx, q.query("update %s set state = ?, last_error = ? where id = ?", q.table()),
+			string(StateFailed), err.Error(), payloadErr.id)
+		if buryErr == nil {
+			buryErr = tx.Commit()
+		}
+		if buryErr != nil {
+			return nil, buryErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	qm.Attempts++
+	qm.AvailableAt = now.Add(visibility)
+	_, err = tx.ExecContext(ctx, q.query("update %s set attempts = ?, available_at = ? where id = ?", q.table()),
+		qm.Attempts, qm.AvailableAt.UnixMilli(), qm.Message.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return qm, nil
+}
+
+// Complete removes a message that was sent
+func (q *SQLQueue) Complete(ctx context.Context, id string) error {
+	_, err := q.DB.ExecContext(ctx, q.query("delete from %s where id = ?", q.table()), id)
+	return err
+}
+
+// Release makes a reserved message due again at a later time
+func (q *SQLQueue) Release(ctx context.Context, id, lastError string, at time.Time) error {
+	_, err := q.DB.ExecContext(ctx, q.query("update %s set last_error = ?, available_at = ? where id = ?", q.table()),
+		lastError, at.UnixMilli(), id)
+	return err
+}
+
+// Bury marks a message as failed
+func (q *SQLQueue) Bury(ctx context.Context, id, lastError string) error {
+	_, err := q.DB.ExecContext(ctx, q.query("update %s set state = ?, last_error = ? where id = ?", q.table()),
+		string(StateFailed), lastError, id)
+	return err
+}
+
+// List returns the messages in a state, or all messages when state is empty, oldest first
+func (q *SQLQueue) List(ctx context.Context, state State) ([]QueuedMessage, error) {
+	var rows *sql.Rows
+	var err error
+	if state == "" {
+		rows, err = q.DB.QueryContext(ctx, q.query("select %s from %s order by created_at", sqlQueueColumns, q.table()))
+	} else {
+		rows, err = q.DB.QueryContext(ctx, q.query("select %s from %s where state = ? order by created_at", sqlQueueColumns, q.table()), string(state))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []QueuedMessage
+	for rows.Next() {
+		qm, err := scanQueuedMessage(rows)
+		var payloadErr *payloadError
+		if err != nil && !errors.As(err, &payloadErr) {
+			return nil, err
+		}
+		messages = append(messages, *qm)
+	}
+
+	return messages, rows.Err()
+}
+
+// Retry makes a failed message due again, or all failed messages when id is empty
+func (q *SQLQueue) Retry(ctx context.Context, id string) (int, error) {
+	query := "update %s set state = ?, attempts = 0, available_at = ? where state = ?"
+	args := []interface{}{string(StateQueued), time.Now().UnixMilli(), string(StateFailed)}
+	if id != "" {
+		query += " and id = ?"
+		args = append(args, id)
+	}
+
+	res, err := q.DB.ExecContext(ctx, q.query(query, q.table()), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Purge removes the messages in a state, or all messages when state is empty
+func (q *SQLQueue) Purge(ctx context.Context, state State) (int, error) {
+	var res sql.Result
+	var err error
+	if state == "" {
+		res, err = q.DB.ExecContext(ctx, q.query("delete from %s", q.table()))
+	} else {
+		res, err = q.DB.ExecContext(ctx, q.query("delete from %s where state = ?", q.table()), string(state))
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanQueuedMessage(row scanner) (*QueuedMessage, error) {
+	var qm QueuedMessage
+	var id, payload, state string
+	var lastError sql.NullString
+	var availableAt, createdAt int64
+
+	if err := row.Scan(&id, &payload, &state, &qm.Attempts, &lastError, &availableAt, &createdAt); err != nil {
+		return nil, err
+	}
+
+	// a message that can't be read is returned with the rest of its row, so it can be listed
+	var err error
+	if jsonErr := json.Unmarshal([]byte(payload), &qm.Message); jsonErr != nil {
+		err = &payloadError{id: id, err: jsonErr}
+	}
+	qm.Message.ID = id
+	qm.State = State(state)
+	qm.LastError = lastError.String
+	qm.AvailableAt = time.UnixMilli(availableAt)
+	qm.CreatedAt = time.UnixMilli(createdAt)
+
+	return &qm, err
+}
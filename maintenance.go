@@ -0,0 +1,167 @@
+package rapidus
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/fouched/rapidus/ratelimit"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const maintenanceCookie = "rapidus_maintenance"
+
+// Maintenance describes an application that is down for maintenance. It is written to
+// tmp/maintenance.json by `rapidus down`, which is shared by every instance using the same root path
+type Maintenance struct {
+	// Allow lists IP addresses and CIDR ranges that can still use the application
+	Allow []string `json:"allow,omitempty"`
+	// Secret, when visited as /<secret>, sets a cookie that bypasses maintenance mode
+	Secret string `json:"secret,omitempty"`
+	// Retry is sent as the Retry-After header, in seconds
+	Retry int       `json:"retry,omitempty"`
+	Since time.Time `json:"since"`
+}
+
+// maintenanceFile caches the parsed maintenance file, until it changes on disk
+type maintenanceFile struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	state   *Maintenance
+}
+
+func (r *Rapidus) maintenancePath() string {
+	return filepath.Join(r.RootPath, "tmp", "maintenance.json")
+}
+
+// DownForMaintenance puts the application into maintenance mode
+func (r *Rapidus) DownForMaintenance(m Maintenance) error {
+	if m.Since.IsZero() {
+		m.Since = time.Now()
+	}
+
+	out, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.maintenancePath(), out, 0644)
+}
+
+// UpFromMaintenance takes the application out of maintenance mode
+func (r *Rapidus) UpFromMaintenance() error {
+	err := os.Remove(r.maintenancePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MaintenanceMode serves the 503 error page while the application is down for maintenance.
+// Allowed IPs, and browsers that visited the secret bypass url, can still use the application
+func (r *Rapidus) MaintenanceMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m, err := r.maintenance.read()
+		if err != nil {
+			r.ErrorLog.Println("maintenance:", err)
+		}
+
+		if m == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		bypass := m.bypassValue()
+		if m.Secret != "" && req.URL.Path == "/"+m.Secret {
+			http.SetCookie(w, &http.Cookie{
+				Name:     maintenanceCookie,
+				Value:    bypass,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.Server.Secure,
+				SameSite: http.SameSiteLaxMode,
+			})
+			http.Redirect(w, req, "/", http.StatusSeeOther)
+			return
+		}
+
+		if m.allows(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if c, err := req.Cookie(maintenanceCookie); err == nil && m.Secret != "" &&
+			subtle.ConstantTimeCompare([]byte(c.Value), []byte(bypass)) == 1 {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if m.Retry > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(m.Retry))
+		}
+		r.ErrorPage(w, req, http.StatusServiceUnavailable)
+	})
+}
+
+// read returns the current maintenance state, or nil when the application is up
+func (f *maintenanceFile) read() (*Maintenance, error) {
+	fi, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.state != nil && fi.ModTime().Equal(f.modTime) {
+		return f.state, nil
+	}
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Maintenance
+	if err = json.Unmarshal(content, &m); err != nil {
+		// a damaged file still means we are down
+		return &Maintenance{}, err
+	}
+
+	f.state, f.modTime = &m, fi.ModTime()
+	return f.state, nil
+}
+
+func (m *Maintenance) allows(req *http.Request) bool {
+	ip := net.ParseIP(ratelimit.KeyByIP(req))
+	if ip == nil {
+		return false
+	}
+
+	for _, allowed := range m.Allow {
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if ip.Equal(net.ParseIP(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// bypassValue is stored in the bypass cookie, so that the secret itself is not
+func (m *Maintenance) bypassValue() string {
+	sum := sha256.Sum256([]byte("maintenance:" + m.Secret))
+	return hex.EncodeToString(sum[:])
+}
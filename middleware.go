@@ -3,21 +3,71 @@ package rapidus
 import (
 	"github.com/justinas/nosurf"
 	"net/http"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
+// CSRFConfig lists the requests that are exempt from CSRF protection, typically API routes
+type CSRFConfig struct {
+	ExemptGlobs   []string
+	ExemptRegexps []*regexp.Regexp
+	// ExemptAuthorization exempts requests with a valid bearer token in the Authorization header,
+	// which browsers never add by themselves. Nothing is exempted until AuthorizationValid is set
+	// to check the token, as a site allowed by CORS could otherwise send any token to skip the check
+	ExemptAuthorization bool
+	AuthorizationValid  func(r *http.Request) bool
+}
+
+// csrfConfigFromEnv reads the CSRF exemptions from .env
+func (r *Rapidus) csrfConfigFromEnv() CSRFConfig {
+	exemptAuthorization, _ := strconv.ParseBool(os.Getenv("CSRF_EXEMPT_AUTHORIZATION"))
+
+	cfg := CSRFConfig{
+		ExemptGlobs:         envList("CSRF_EXEMPT_GLOBS"),
+		ExemptAuthorization: exemptAuthorization,
+	}
+
+	for _, expr := range envList("CSRF_EXEMPT_REGEXPS") {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			r.ErrorLog.Printf("invalid CSRF_EXEMPT_REGEXPS entry %s: %v", expr, err)
+			continue
+		}
+		cfg.ExemptRegexps = append(cfg.ExemptRegexps, re)
+	}
+
+	return cfg
+}
+
 func (r *Rapidus) SessionLoad(next http.Handler) http.Handler {
-	r.InfoLog.Println("SessionLoad")
-	return r.Session.LoadAndSave(next)
+	return r.Session.LoadAndSave(r.logSessionUser(next))
 }
 
 func (r *Rapidus) NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
-	r.InfoLog.Println("nosurf CSRF loaded")
 	secure, _ := strconv.ParseBool(r.config.cookie.secure)
 
-	// to allow some URLS
-	// csrfHandler.ExemptGlob("/some-api/*")
+	// to allow some URLS, set CSRF_EXEMPT_GLOBS, e.g. /some-api/*, or CSRF_EXEMPT_REGEXPS
+	csrfHandler.ExemptGlobs(r.CSRF.ExemptGlobs...)
+	for _, re := range r.CSRF.ExemptRegexps {
+		csrfHandler.ExemptRegexp(re)
+	}
+
+	if r.CSRF.ExemptAuthorization {
+		csrfHandler.ExemptFunc(func(req *http.Request) bool {
+			if r.CSRF.AuthorizationValid == nil || !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+				return false
+			}
+			return r.CSRF.AuthorizationValid(req)
+		})
+	}
+
+	// browsers post CSP violation reports without a token
+	if r.Security.CSPReportURI != "" {
+		csrfHandler.ExemptPath(r.Security.CSPReportURI)
+	}
 
 	// ---------------------------------------------------------------------------------------------
 	// SameSite=Strict—the cookie is only sent for requests that originate on the same domain.
@@ -35,7 +85,9 @@ func (r *Rapidus) NoSurf(next http.Handler) http.Handler {
 	// ---------------------------------------------------------------------------------------------
 
 	// !!! NB NOTE NB !!!
-	// If you do not use POST e.g. for HTMX delete, you need to manually pass the header
+	// If you do not use POST e.g. for HTMX delete, the token has to be sent as a header. Pages rendered
+	// with Render.Page or Render.Template have it added to their body element as hx-headers, so every
+	// htmx request made from them sends it. Anywhere else you need to manually pass the header
 	// <a href="#" hx-swap="none" hx-delete="/your/endpoint" hx-headers='{"X-CSRF-Token": "{{$csrfToken}}"}'>Delete</a>
 
 	csrfHandler.SetBaseCookie(http.Cookie{
@@ -0,0 +1,35 @@
+package rapidus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRapidus_NoSurf_ExemptAuthorization(t *testing.T) {
+	app := newTestApp()
+	app.CSRF.ExemptAuthorization = true
+	handler := app.NoSurf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	post := func() int {
+		req := httptest.NewRequest("POST", "/api/items", nil)
+		req.Header.Set("Authorization", "Bearer anything")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(); code != http.StatusBadRequest {
+		t.Errorf("request exempted without AuthorizationValid, got %d", code)
+	}
+
+	app.CSRF.AuthorizationValid = func(r *http.Request) bool { return false }
+	if code := post(); code != http.StatusBadRequest {
+		t.Errorf("request with an invalid token exempted, got %d", code)
+	}
+
+	app.CSRF.AuthorizationValid = func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer anything" }
+	if code := post(); code != http.StatusOK {
+		t.Errorf("request with a valid token not exempted, got %d", code)
+	}
+}
@@ -0,0 +1,220 @@
+package rapidus
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fouched/rapidus/urlsigner"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Params are the values used to build the URL of a named route. Values for the route's URL
+// parameters, e.g. {id}, are placed in the path and all other values in the query string.
+// A trailing wildcard is given as "*"
+type Params map[string]interface{}
+
+// Name registers a name for a route pattern and returns the pattern, so routes can be named where
+// they are added: app.Routes.Get(app.Name("user", "/users/{id}"), handler). The pattern must be the
+// full path of the route, including the patterns of any sub routers it is mounted on. A pattern can
+// only have one name, as the name of the route that handles a request is the purpose signed URLs
+// are checked for
+func (r *Rapidus) Name(name, pattern string) string {
+	if r.routeNames == nil {
+		r.routeNames = make(map[string]string)
+		r.routePatterns = make(map[string]string)
+	}
+
+	if _, exists := r.routeNames[name]; exists {
+		panic(fmt.Sprintf("rapidus: route name %q is already registered", name))
+	}
+	if other, exists := r.routePatterns[pattern]; exists {
+		panic(fmt.Sprintf("rapidus: route pattern %s is already named %q", pattern, other))
+	}
+
+	r.routeNames[name] = pattern
+	r.routePatterns[pattern] = name
+	return pattern
+}
+
+// NamedRoute adds a route to Routes and registers its name
+func (r *Rapidus) NamedRoute(method, name, pattern string, handler http.HandlerFunc) {
+	r.Routes.MethodFunc(method, r.Name(name, pattern), handler)
+}
+
+// Path returns the path of a named route, e.g. /users/42?tab=orders for the route
+// /users/{id} and Params{"id": 42, "tab": "orders"}. It panics when the name is not registered
+// or a URL parameter of the route is missing, as both are programming errors
+func (r *Rapidus) Path(name string, params Params) string {
+	pattern, ok := r.routeNames[name]
+	if !ok {
+		panic(fmt.Sprintf("rapidus: no route named %q", name))
+	}
+
+	path, err := buildPath(pattern, params)
+	if err != nil {
+		panic(fmt.Sprintf("rapidus: route %q: %s", name, err))
+	}
+
+	return path
+}
+
+// URL returns the absolute URL of a named route, using Server.URL
+func (r *Rapidus) URL(name string, params Params) string {
+	return strings.TrimSuffix(r.Server.URL, "/") + r.Path(name, params)
+}
+
+// SignedURL returns the absolute URL of a named route, signed with the encryption key so it can't
+// be changed, and valid for ttl. The route name is the purpose of the signature, so the URL is only
+// accepted by that route. Routes receiving signed URLs are protected with ValidSignature
+func (r *Rapidus) SignedURL(name string, params Params, ttl time.Duration) (string, error) {
+	return r.urlSigner().Sign(r.URL(name, params), urlsigner.Options{TTL: ttl, Purpose: name})
+}
+
+// OneTimeURL returns a signed URL like SignedURL, that is only accepted once. Used URLs are
+// tracked in the cache, so without CACHE it is a signed URL that is accepted until it expires
+func (r *Rapidus) OneTimeURL(name string, params Params, ttl time.Duration) (string, error) {
+	signer := r.urlSigner()
+	return signer.Sign(r.URL(name, params), urlsigner.Options{TTL: ttl, Purpose: name, OneTime: signer.OneTime()})
+}
+
+// HasValidSignature reports whether the request URL was created by SignedURL or OneTimeURL for
+// the route that handles the request, has not been changed and has not expired. A one-time URL
+// is used up by it
+func (r *Rapidus) HasValidSignature(req *http.Request) bool {
+	return r.logSignatureError(r.urlSigner().Verify(r.requestURL(req), r.routeName(req)))
+}
+
+// CheckSignature reports whether the request URL is valid like HasValidSignature, without using
+// up a one-time URL. Use it for a page with a form, and pass the URL to UseSignedURL when the form
+// is posted, so that mail scanners that fetch the links in a message don't use them up
+func (r *Rapidus) CheckSignature(req *http.Request) bool {
+	return r.logSignatureError(r.urlSigner().Check(r.requestURL(req), r.routeName(req)))
+}
+
+// UseSignedURL reports whether signedURL, which may be relative to Server.URL, was created by
+// SignedURL or OneTimeURL for the named route and is still valid, and uses it up if it is a
+// one-time URL
+func (r *Rapidus) UseSignedURL(signedURL, name string) bool {
+	if strings.HasPrefix(signedURL, "/") {
+		signedURL = strings.TrimSuffix(r.Server.URL, "/") + signedURL
+	}
+	return r.logSignatureError(r.urlSigner().Verify(signedURL, name))
+}
+
+func (r *Rapidus) requestURL(req *http.Request) string {
+	return strings.TrimSuffix(r.Server.URL, "/") + req.URL.RequestURI()
+}
+
+func (r *Rapidus) logSignatureError(err error) bool {
+	if err != nil && !errors.Is(err, urlsigner.ErrInvalidSignature) {
+		r.InfoLog.Println(err)
+	}
+	return err == nil
+}
+
+// ValidSignature is middleware that rejects requests with a URL that was not created by
+// SignedURL, was changed, or has expired
+func (r *Rapidus) ValidSignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.HasValidSignature(req) {
+			r.ErrorPage(w, req, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// urlSigner returns the signer for signed URLs, creating one if needed
+func (r *Rapidus) urlSigner() *urlsigner.URLSigner {
+	if r.URLSigner == nil {
+		r.URLSigner = r.createURLSigner(r.previousKeys())
+	}
+	return r.URLSigner
+}
+
+// createURLSigner creates the signer for signed URLs from KEY and the previous keys, with the
+// cache that keeps track of used one-time URLs
+func (r *Rapidus) createURLSigner(previousKeys [][]byte) *urlsigner.URLSigner {
+	signer := urlsigner.New([]byte(r.EncryptionKey), previousKeys...)
+	signer.Cache = r.Cache
+	return signer
+}
+
+// routeName returns the name of the route that matched the request, or "" if it has no name
+func (r *Rapidus) routeName(req *http.Request) string {
+	rctx := chi.RouteContext(req.Context())
+	if rctx == nil {
+		return ""
+	}
+
+	return r.routePatterns[rctx.RoutePattern()]
+}
+
+// buildPath replaces the URL parameters in a chi route pattern with their values, and adds
+// the remaining params to the query string
+func buildPath(pattern string, params Params) (string, error) {
+	used := make(map[string]bool)
+
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '{':
+			end := closingBrace(pattern, i)
+			if end < 0 {
+				return "", fmt.Errorf("invalid pattern %s", pattern)
+			}
+
+			key, _, _ := strings.Cut(pattern[i+1:end], ":")
+			value, ok := params[key]
+			if !ok {
+				return "", fmt.Errorf("missing URL parameter %s", key)
+			}
+
+			b.WriteString(url.PathEscape(fmt.Sprint(value)))
+			used[key] = true
+			i = end
+		case c == '*' && i == len(pattern)-1:
+			if value, ok := params["*"]; ok {
+				b.WriteString(fmt.Sprint(value))
+				used["*"] = true
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	query := url.Values{}
+	for key, value := range params {
+		if !used[key] {
+			query.Set(key, fmt.Sprint(value))
+		}
+	}
+
+	if len(query) > 0 {
+		b.WriteString("?" + query.Encode())
+	}
+
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the one at start, allowing for
+// braces in the regular expression of a parameter, e.g. {id:[0-9]{4}}
+func closingBrace(pattern string, start int) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
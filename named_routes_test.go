@@ -0,0 +1,145 @@
+package rapidus
+
+import (
+	"github.com/fouched/rapidus/cache"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// addCache is the part of a cache one-time URLs use
+type addCache struct {
+	cache.Cache
+	used map[string]bool
+}
+
+func (c *addCache) Has(key string) (bool, error) {
+	if !c.used[key] {
+		return false, cache.ErrNotFound
+	}
+	return true, nil
+}
+
+func (c *addCache) Add(key string, _ interface{}, _ ...int) (bool, error) {
+	if c.used[key] {
+		return false, nil
+	}
+	c.used[key] = true
+	return true, nil
+}
+
+func newSignedURLApp(c cache.Cache) (*Rapidus, *chi.Mux) {
+	app := newTestApp()
+	app.EncryptionKey = "01234567890123456789012345678901"
+	app.Server.URL = "https://example.com"
+	app.Cache = c
+
+	mux := chi.NewRouter()
+	mux.Get(app.Name("reset-password", "/users/reset-password"), func(w http.ResponseWriter, r *http.Request) {
+		if !app.CheckSignature(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	return app, mux
+}
+
+func TestRapidus_OneTimeURL(t *testing.T) {
+	app, mux := newSignedURLApp(&addCache{used: make(map[string]bool)})
+
+	link, err := app.OneTimeURL("reset-password", Params{"email": "me@here.com"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(link, "once=") {
+		t.Fatal("no one-time nonce in", link)
+	}
+
+	u, _ := url.Parse(link)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", u.RequestURI(), nil))
+		if w.Code != http.StatusOK {
+			t.Error("showing the form used up the link, got", w.Code)
+		}
+	}
+
+	if !app.UseSignedURL(u.RequestURI(), "reset-password") {
+		t.Error("link not accepted when the form is posted")
+	}
+	if app.UseSignedURL(u.RequestURI(), "reset-password") {
+		t.Error("link accepted twice")
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", u.RequestURI(), nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Error("form shown for a used link, got", w.Code)
+	}
+
+	tampered := strings.Replace(u.RequestURI(), "me%40here.com", "you%40here.com", 1)
+	if app.UseSignedURL(tampered, "reset-password") {
+		t.Error("changed link accepted")
+	}
+}
+
+func TestRapidus_OneTimeURL_NoCache(t *testing.T) {
+	app, mux := newSignedURLApp(nil)
+
+	link, err := app.OneTimeURL("reset-password", Params{"email": "me@here.com"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(link, "once=") {
+		t.Error("one-time link created without a cache:", link)
+	}
+
+	u, _ := url.Parse(link)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", u.RequestURI(), nil))
+	if w.Code != http.StatusOK {
+		t.Error("signed link rejected without a cache, got", w.Code)
+	}
+	if !app.UseSignedURL(u.RequestURI(), "reset-password") {
+		t.Error("signed link not accepted without a cache")
+	}
+}
+
+func TestRapidus_Name(t *testing.T) {
+	app := newTestApp()
+	mux := chi.NewRouter()
+	routeName := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(app.routeName(r)))
+	}
+	mux.Get(app.Name("users", "/users"), routeName)
+	mux.Get(app.Name("user", "/users/{id}"), routeName)
+	mux.Get("/about", routeName)
+
+	for path, name := range map[string]string{"/users": "users", "/users/42": "user", "/about": ""} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != name {
+			t.Errorf("%s: expected route %q, got %q", path, name, w.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name, pattern string
+	}{
+		{"user", "/members/{id}"},
+		{"member", "/users/{id}"},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s %s: expected a panic", tt.name, tt.pattern)
+				}
+			}()
+			app.Name(tt.name, tt.pattern)
+		}()
+	}
+}
@@ -0,0 +1,397 @@
+package pagecache
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/alexedwards/scs/v2"
+	"github.com/fouched/rapidus/cache"
+	"github.com/justinas/nosurf"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures which responses are cached, and what makes two requests for the same
+// url different. The CSP nonce and the CSRF token of the request are replaced in cached pages with
+// those of the request they are served to
+type Options struct {
+	Cache  cache.Cache
+	TTL    time.Duration
+	Prefix string
+	// VaryHeaders, VaryCookies and VarySession (session keys) are part of the cache key
+	VaryHeaders []string
+	VaryCookies []string
+	VarySession []string
+	Session     *scs.SessionManager
+	// Bypass skips the cache for a request, e.g. for logged in users
+	Bypass func(r *http.Request) bool
+}
+
+// entry is a cached response
+type entry struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	Modified time.Time
+	// Tags holds the version of each tag when the response was cached
+	Tags map[string]string
+	// Placeholders stand in the body for the per-request values of the request that was cached
+	Placeholders []placeholder
+}
+
+// placeholder is a random text that stands in a cached body for a secret of the request, in one
+// of the forms it is written in
+type placeholder struct {
+	Text   string
+	Secret int
+	Form   int
+}
+
+// secrets returns the values that differ for every request, and must not be served to others:
+// the CSP nonce set by SecurityHeaders and the CSRF token set by NoSurf
+func secrets(r *http.Request) []string {
+	return []string{templ.GetNonce(r.Context()), nosurf.Token(r)}
+}
+
+// secretForms are the ways a secret is written in a page: as is by templ and in JSON, escaped by
+// html/template in attributes, and escaped by html/template in scripts
+var secretForms = []func(string) string{
+	func(s string) string { return s },
+	strings.NewReplacer("+", "&#43;").Replace,
+	strings.NewReplacer("+", `\u002b`, "/", `\/`).Replace,
+}
+
+type tagsKey struct{}
+
+// Tag marks the response to the current request with tags, so that it can be purged with Purge
+func Tag(r *http.Request, tags ...string) {
+	if t, ok := r.Context().Value(tagsKey{}).(*[]string); ok {
+		*t = append(*t, tags...)
+	}
+}
+
+// Purge removes every cached response tagged with one of tags. Instead of finding the responses,
+// the tag gets a new version, which makes responses cached with the old version stale
+func Purge(c cache.Cache, prefix string, tags ...string) error {
+	for _, tag := range tags {
+		if err := c.Forget(tagKey(prefix, tag)); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler creates the response caching middleware
+func Handler(opts Options) func(http.Handler) http.Handler {
+	if opts.Prefix == "" {
+		opts.Prefix = "pagecache"
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = 5 * time.Minute
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" ||
+				(opts.Bypass != nil && opts.Bypass(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := opts.key(r)
+			if e, ok := opts.load(key); ok {
+				e.write(w, r, "HIT")
+				return
+			}
+
+			var tags []string
+			rec := &recorder{header: make(http.Header)}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), tagsKey{}, &tags)))
+
+			e := &entry{
+				Status:   rec.status(),
+				Header:   rec.header,
+				Body:     rec.body.Bytes(),
+				Modified: time.Now().UTC().Truncate(time.Second),
+			}
+
+			if !e.cacheable() {
+				e.write(w, r, "")
+				return
+			}
+
+			// the page is served as it was rendered, and cached with placeholders for the values of
+			// this request. It can't be cached when they are in it in a form that is not replaced
+			cached := *e
+			if !cached.replaceSecrets(r) {
+				e.write(w, r, "")
+				return
+			}
+
+			// pages with placeholders differ for every request, so they are not validated
+			if len(cached.Placeholders) == 0 {
+				sum := sha256.Sum256(cached.Body)
+				cached.Header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+				cached.Header.Set("Last-Modified", cached.Modified.Format(http.TimeFormat))
+			}
+			_ = opts.store(key, &cached, tags)
+
+			e.write(w, r, "MISS")
+		})
+	}
+}
+
+// key hashes everything that makes a response different. HEAD responses have no body, so they
+// are cached apart from GET responses
+func (o *Options) key(r *http.Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.Host, r.URL.RequestURI())
+
+	for _, name := range o.VaryHeaders {
+		fmt.Fprintf(h, "h:%s=%s\n", name, r.Header.Get(name))
+	}
+
+	for _, name := range o.VaryCookies {
+		if c, err := r.Cookie(name); err == nil {
+			fmt.Fprintf(h, "c:%s=%s\n", name, c.Value)
+		}
+	}
+
+	if o.Session != nil {
+		for _, name := range o.VarySession {
+			fmt.Fprintf(h, "s:%s=%v\n", name, o.Session.Get(r.Context(), name))
+		}
+	}
+
+	return o.Prefix + ":page:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (o *Options) load(key string) (*entry, bool) {
+	cached, err := o.Cache.Get(key)
+	if err != nil {
+		return nil, false
+	}
+
+	b, ok := cached.([]byte)
+	if !ok {
+		return nil, false
+	}
+
+	var e entry
+	if err = gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return nil, false
+	}
+
+	// the response is stale when one of its tags has been purged since
+	for tag, version := range e.Tags {
+		current, err := o.Cache.Get(tagKey(o.Prefix, tag))
+		if err != nil || current != version {
+			return nil, false
+		}
+	}
+
+	return &e, true
+}
+
+func (o *Options) store(key string, e *entry, tags []string) error {
+	e.Tags = make(map[string]string)
+	for _, tag := range tags {
+		version, err := o.tagVersion(tag)
+		if err != nil {
+			return err
+		}
+		e.Tags[tag] = version
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(e); err != nil {
+		return err
+	}
+
+	return o.Cache.Set(key, b.Bytes(), int(o.TTL.Seconds()))
+}
+
+// tagVersion returns the current version of a tag, creating one if the tag is new or was purged
+func (o *Options) tagVersion(tag string) (string, error) {
+	key := tagKey(o.Prefix, tag)
+	if current, err := o.Cache.Get(key); err == nil {
+		if version, ok := current.(string); ok {
+			return version, nil
+		}
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	version := hex.EncodeToString(b)
+	return version, o.Cache.Set(key, version)
+}
+
+func tagKey(prefix, tag string) string {
+	return prefix + ":tag:" + tag
+}
+
+// replaceSecrets replaces the secrets of a request in the body with placeholders. It reports
+// whether the body is free of them, which it is not when they are written in another form
+func (e *entry) replaceSecrets(r *http.Request) bool {
+	body := e.Body
+	for i, secret := range secrets(r) {
+		if secret == "" {
+			continue
+		}
+
+		for j, form := range secretForms {
+			written := []byte(form(secret))
+			if !bytes.Contains(body, written) {
+				continue
+			}
+
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				return false
+			}
+			p := placeholder{Text: "pagecache-" + hex.EncodeToString(b), Secret: i, Form: j}
+			body = bytes.ReplaceAll(body, written, []byte(p.Text))
+			e.Placeholders = append(e.Placeholders, p)
+		}
+
+		for _, run := range alphanumericRuns(secret, 6) {
+			if bytes.Contains(body, []byte(run)) {
+				return false
+			}
+		}
+	}
+
+	e.Body = body
+	return true
+}
+
+// fillSecrets returns the body with the placeholders replaced by the secrets of a request
+func (e *entry) fillSecrets(r *http.Request) []byte {
+	if len(e.Placeholders) == 0 {
+		return e.Body
+	}
+
+	values := secrets(r)
+	pairs := make([]string, 0, 2*len(e.Placeholders))
+	for _, p := range e.Placeholders {
+		pairs = append(pairs, p.Text, secretForms[p.Form](values[p.Secret]))
+	}
+	return []byte(strings.NewReplacer(pairs...).Replace(string(e.Body)))
+}
+
+// alphanumericRuns returns the runs of at least min letters and digits in s, which are part of
+// every form s can be written in
+func alphanumericRuns(s string, min int) []string {
+	var runs []string
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || s[i] >= '0' && s[i] <= '9') {
+			continue
+		}
+		if i-start >= min {
+			runs = append(runs, s[start:i])
+		}
+		start = i + 1
+	}
+	return runs
+}
+
+// cacheable reports whether the response may be shared with other clients
+func (e *entry) cacheable() bool {
+	if e.Status != http.StatusOK || e.Header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	cacheControl := strings.ToLower(e.Header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "private") && !strings.Contains(cacheControl, "no-store")
+}
+
+// write sends the response, or 304 Not Modified when the client's copy is still current. The
+// X-Cache header is only set to status for a response that is cached
+func (e *entry) write(w http.ResponseWriter, r *http.Request, status string) {
+	h := w.Header()
+	for k, v := range e.Header {
+		h[k] = v
+	}
+
+	if status != "" {
+		h.Set("X-Cache", status)
+	}
+
+	if e.Header.Get("ETag") != "" && notModified(r, e) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := e.fillSecrets(r)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+func notModified(r *http.Request, e *entry) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		etag := e.Header.Get("ETag")
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !e.Modified.After(since)
+	}
+
+	return false
+}
+
+// recorder buffers a response so that it can be cached before it is sent
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.code == 0 {
+		rec.code = status
+	}
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return rec.body.Write(p)
+}
+
+// Flush is a no-op, as the response is sent in one go once it is complete
+func (rec *recorder) Flush() {}
+
+func (rec *recorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
@@ -0,0 +1,209 @@
+package pagecache
+
+import (
+	"github.com/a-h/templ"
+	"github.com/fouched/rapidus/cache"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryCache is a minimal cache.Cache for testing
+type memoryCache map[string]interface{}
+
+func (m memoryCache) Has(key string) (bool, error) {
+	if _, ok := m[key]; !ok {
+		return false, cache.ErrNotFound
+	}
+	return true, nil
+}
+
+func (m memoryCache) Get(key string) (interface{}, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m memoryCache) Set(key string, value interface{}, _ ...int) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryCache) Forget(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memoryCache) EmptyByMatch(prefix string) error {
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			delete(m, k)
+		}
+	}
+	return nil
+}
+
+func (m memoryCache) Empty() error {
+	return m.EmptyByMatch("")
+}
+
+func newTestHandler(c memoryCache, calls *int) http.Handler {
+	return Handler(Options{
+		Cache:       c,
+		TTL:         time.Minute,
+		VaryHeaders: []string{"Accept-Language"},
+		Bypass: func(r *http.Request) bool {
+			return r.URL.Query().Get("user") != ""
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		Tag(r, "posts")
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<p>" + r.Header.Get("Accept-Language") + "</p>"))
+	}))
+}
+
+func get(h http.Handler, url string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", url, nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler_Caching(t *testing.T) {
+	calls := 0
+	h := newTestHandler(memoryCache{}, &calls)
+
+	w := get(h, "/posts", nil)
+	if w.Header().Get("X-Cache") != "MISS" || calls != 1 {
+		t.Error("first request not a miss")
+	}
+
+	w = get(h, "/posts", nil)
+	if w.Header().Get("X-Cache") != "HIT" || calls != 1 {
+		t.Error("second request not served from cache")
+	}
+
+	if w.Body.String() != "<p></p>" || w.Header().Get("Content-Type") != "text/html" {
+		t.Error("cached response differs:", w.Body.String())
+	}
+
+	get(h, "/posts", map[string]string{"Accept-Language": "af"})
+	if calls != 2 {
+		t.Error("cache does not vary on header")
+	}
+
+	get(h, "/posts?user=1", nil)
+	get(h, "/posts?user=1", nil)
+	if calls != 4 {
+		t.Error("bypassed requests served from cache")
+	}
+}
+
+func TestHandler_Head(t *testing.T) {
+	calls := 0
+	h := newTestHandler(memoryCache{}, &calls)
+
+	r := httptest.NewRequest("HEAD", "/posts", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	w := get(h, "/posts", nil)
+	if calls != 2 || w.Body.String() != "<p></p>" {
+		t.Errorf("GET served the cached HEAD response: %q", w.Body.String())
+	}
+}
+
+func TestHandler_Nonce(t *testing.T) {
+	calls := 0
+	h := Handler(Options{Cache: memoryCache{}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		nonce := templ.GetNonce(r.Context())
+		_, _ = w.Write([]byte(`<script nonce="` + nonce + `"></script>`))
+		if r.URL.Query().Get("escaped") != "" {
+			_, _ = w.Write([]byte(`<a href="?nonce=` + url.QueryEscape(nonce) + `">`))
+		}
+	}))
+
+	serve := func(target, nonce string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r.WithContext(templ.WithNonce(r.Context(), nonce)))
+		return w
+	}
+
+	serve("/", "firstNonce+value/1==")
+	w := serve("/", "secondNonce+value/2==")
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.String() != `<script nonce="secondNonce+value/2=="></script>` {
+		t.Errorf("expected the cached page with the nonce of the request, got %q", w.Body.String())
+	}
+	if w.Header().Get("ETag") != "" {
+		t.Error("a page that differs for every request has an ETag")
+	}
+
+	// a nonce written in a form that is not replaced keeps the page out of the cache
+	serve("/?escaped=1", "firstNonce+value/1==")
+	if w = serve("/?escaped=1", "secondNonce+value/2=="); calls != 3 || w.Header().Get("X-Cache") != "" {
+		t.Errorf("page with an escaped nonce served from cache: %q", w.Body.String())
+	}
+}
+
+func TestHandler_Conditional(t *testing.T) {
+	calls := 0
+	h := newTestHandler(memoryCache{}, &calls)
+
+	w := get(h, "/posts", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatal("ETag or Last-Modified not set")
+	}
+
+	w = get(h, "/posts", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() > 0 {
+		t.Error("expected 304 for matching ETag, got", w.Code)
+	}
+
+	w = get(h, "/posts", map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)})
+	if w.Code != http.StatusNotModified {
+		t.Error("expected 304 for If-Modified-Since, got", w.Code)
+	}
+
+	w = get(h, "/posts", map[string]string{"If-None-Match": `"other"`})
+	if w.Code != http.StatusOK {
+		t.Error("expected 200 for different ETag, got", w.Code)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	calls := 0
+	c := memoryCache{}
+	h := newTestHandler(c, &calls)
+
+	get(h, "/posts", nil)
+	get(h, "/posts", nil)
+	if calls != 1 {
+		t.Fatal("response not cached")
+	}
+
+	err := Purge(c, "pagecache", "posts")
+	if err != nil {
+		t.Error(err)
+	}
+
+	get(h, "/posts", nil)
+	if calls != 2 {
+		t.Error("purged response served from cache")
+	}
+
+	get(h, "/posts", nil)
+	if calls != 2 {
+		t.Error("response not cached again after purge")
+	}
+}
@@ -0,0 +1,88 @@
+package rapidus
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"sort"
+)
+
+// Problem is an RFC 7807 problem details response
+type Problem struct {
+	XMLName  xml.Name     `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type     string       `json:"type" xml:"type"`
+	Title    string       `json:"title" xml:"title"`
+	Status   int          `json:"status" xml:"status"`
+	Detail   string       `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty" xml:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty" xml:"errors>error,omitempty"`
+}
+
+// FieldError is the validation error of a single field
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Message string `json:"message" xml:"message"`
+}
+
+// NewProblem creates a problem for status, with an optional detail message
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WriteProblem writes a problem as application/problem+json, or as application/problem+xml
+// when the client prefers XML
+func (r *Rapidus) WriteProblem(w http.ResponseWriter, req *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = req.URL.Path
+	}
+
+	var out []byte
+	var err error
+	if negotiate(req, "application/json", "application/xml") == "application/xml" {
+		w.Header().Set("Content-Type", "application/problem+xml")
+		out, err = xml.Marshal(p)
+	} else {
+		w.Header().Set("Content-Type", "application/problem+json")
+		out, err = json.Marshal(p)
+	}
+
+	if err != nil {
+		r.ErrorLog.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(out)
+}
+
+// ErrorProblem writes a problem response for status, with an optional detail message
+func (r *Rapidus) ErrorProblem(w http.ResponseWriter, req *http.Request, status int, detail string) {
+	r.WriteProblem(w, req, NewProblem(status, detail))
+}
+
+// ValidationProblem writes a 422 problem response listing the errors of each invalid field
+func (r *Rapidus) ValidationProblem(w http.ResponseWriter, req *http.Request, v *Validation) {
+	p := NewProblem(http.StatusUnprocessableEntity, "One or more fields are invalid.")
+	p.Errors = fieldErrors(v.Errors)
+	r.WriteProblem(w, req, p)
+}
+
+// fieldErrors converts validation errors to a list, sorted by field for stable output
+func fieldErrors(errors map[string]string) []FieldError {
+	var result []FieldError
+	for field, message := range errors {
+		result = append(result, FieldError{Field: field, Message: message})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Field < result[j].Field
+	})
+
+	return result
+}
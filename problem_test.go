@@ -0,0 +1,111 @@
+package rapidus
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRapidus_WriteProblem(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+	}{
+		{"default", "", "application/problem+json"},
+		{"json", "application/json", "application/problem+json"},
+		{"problem json", "application/problem+json", "application/problem+json"},
+		{"xml", "application/xml", "application/problem+xml"},
+		{"prefers xml", "application/json;q=0.5, application/xml", "application/problem+xml"},
+		{"browser", "text/html", "application/problem+json"},
+	}
+
+	app := newTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/orders/7", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			app.ErrorProblem(w, req, http.StatusNotFound, "order 7 does not exist")
+
+			if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("expected 404 %s, got %d %s", tt.contentType, w.Code, w.Header().Get("Content-Type"))
+			}
+
+			var p Problem
+			var err error
+			if tt.contentType == "application/problem+xml" {
+				err = xml.Unmarshal(w.Body.Bytes(), &p)
+			} else {
+				err = json.Unmarshal(w.Body.Bytes(), &p)
+			}
+			if err != nil {
+				t.Fatalf("%q: %v", w.Body.String(), err)
+			}
+
+			want := Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "order 7 does not exist", Instance: "/orders/7"}
+			p.XMLName = xml.Name{}
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("expected %+v, got %+v", want, p)
+			}
+		})
+	}
+}
+
+func TestRapidus_ValidationProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors map[string]string
+		want   []FieldError
+	}{
+		{"none", map[string]string{}, nil},
+		{"one", map[string]string{"email": "Invalid email"}, []FieldError{{"email", "Invalid email"}}},
+		{
+			"sorted by field",
+			map[string]string{"name": "This field cannot be blank", "age": "Must be a number", "email": "Invalid email"},
+			[]FieldError{{"age", "Must be a number"}, {"email", "Invalid email"}, {"name", "This field cannot be blank"}},
+		},
+	}
+
+	app := newTestApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users", nil)
+			w := httptest.NewRecorder()
+
+			app.ValidationProblem(w, req, &Validation{Errors: tt.errors})
+
+			var p Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusUnprocessableEntity || p.Status != http.StatusUnprocessableEntity {
+				t.Errorf("expected 422, got %d %d", w.Code, p.Status)
+			}
+			if !reflect.DeepEqual(p.Errors, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, p.Errors)
+			}
+		})
+	}
+}
+
+func TestRapidus_ValidationProblem_xml(t *testing.T) {
+	req := httptest.NewRequest("POST", "/users", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+
+	newTestApp().ValidationProblem(w, req, &Validation{Errors: map[string]string{"b": "second", "a": "first"}})
+
+	want := `<problem xmlns="urn:ietf:rfc:7807"><type>about:blank</type><title>Unprocessable Entity</title>` +
+		`<status>422</status><detail>One or more fields are invalid.</detail><instance>/users</instance>` +
+		`<errors><error><field>a</field><message>first</message></error><error><field>b</field><message>second</message></error></errors></problem>`
+	if w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+}
@@ -1,23 +1,32 @@
 package rapidus
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/a-h/templ"
 	"github.com/alexedwards/scs/v2"
 	"github.com/dgraph-io/badger/v4"
+	"github.com/fouched/rapidus/binding"
 	"github.com/fouched/rapidus/cache"
+	"github.com/fouched/rapidus/encryption"
 	"github.com/fouched/rapidus/mailer"
+	"github.com/fouched/rapidus/ratelimit"
 	"github.com/fouched/rapidus/render"
-
-	//"github.com/fouched/rapidus/render"
+	"github.com/fouched/rapidus/schema"
 	"github.com/fouched/rapidus/session"
+	"github.com/fouched/rapidus/urlsigner"
 	"github.com/go-chi/chi/v5"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -39,10 +48,26 @@ type Rapidus struct {
 	DB            Database
 	config        config // no reason to export this
 	EncryptionKey string
+	Encryption    *encryption.Encryption
 	RedisClient   *redis.Client
 	Cache         cache.Cache
 	Mail          mailer.Mail
 	Server        Server
+	Security      SecurityConfig
+	// TrustedProxies are the proxies RealIP reads the client IP from, set from TRUSTED_PROXIES
+	TrustedProxies []netip.Prefix
+	CSRF           CSRFConfig
+	AccessLogs     AccessLogConfig
+	Schemas        *schema.Registry
+	Rules          *binding.Validator
+	URLSigner      *urlsigner.URLSigner
+
+	errorPages      map[int]templ.Component
+	rateLimitMemory *ratelimit.MemoryStore
+	maintenance     *maintenanceFile
+	routeNames      map[string]string
+	routePatterns   map[string]string
+	closeMailQueue  func() error
 }
 
 type Server struct {
@@ -93,7 +118,41 @@ func (r *Rapidus) New(rootPath string) error {
 	r.Version = version
 	r.RootPath = rootPath
 	r.Mail = r.createMailer()
-	r.Routes = r.routes().(*chi.Mux)
+	r.Security = securityConfigFromEnv()
+	r.TrustedProxies, err = trustedProxiesFromEnv()
+	if err != nil {
+		return err
+	}
+	r.CSRF = r.csrfConfigFromEnv()
+	r.AccessLogs = r.accessLogConfigFromEnv()
+	r.Schemas, err = r.loadSchemas()
+	if err != nil {
+		return err
+	}
+	r.Rules = r.createRules()
+
+	// setup config, which the connections below use
+	r.config = config{
+		port:     os.Getenv("PORT"),
+		renderer: os.Getenv("RENDERER"),
+		cookie: cookieConfig{
+			name:     os.Getenv("COOKIE_NAME"),
+			lifetime: os.Getenv("COOKIE_LIFETIME"),
+			persist:  os.Getenv("COOKIE_PERSIST"),
+			secure:   os.Getenv("COOKIE_SECURE"),
+			domain:   os.Getenv("COOKIE_DOMAIN"),
+		},
+		sessionType: os.Getenv("SESSION_TYPE"),
+		database: databaseConfig{
+			dsn:      r.BuildDSN(),
+			database: os.Getenv("DATABASE_TYPE"),
+		},
+		redis: redisConfig{
+			host:     os.Getenv("REDIS_HOST"),
+			password: os.Getenv("REDIS_PASSWORD"),
+			prefix:   os.Getenv("REDIS_PREFIX"),
+		},
+	}
 
 	// connect to database if specified
 	if os.Getenv("DATABASE_TYPE") != "" {
@@ -113,6 +172,10 @@ func (r *Rapidus) New(rootPath string) error {
 		r.RedisClient = r.createRedisClient()
 	}
 
+	if os.Getenv("CACHE") == "redis" {
+		r.Cache = &cache.RedisCache{Conn: r.RedisClient, Prefix: r.config.redis.prefix}
+	}
+
 	if os.Getenv("CACHE") == "badger" {
 		badgerCache = r.createBadgerCache()
 		r.Cache = badgerCache
@@ -125,29 +188,6 @@ func (r *Rapidus) New(rootPath string) error {
 		}()
 	}
 
-	// setup config
-	r.config = config{
-		port:     os.Getenv("PORT"),
-		renderer: os.Getenv("RENDERER"), // we probably don't need this with templ
-		cookie: cookieConfig{
-			name:     os.Getenv("COOKIE_NAME"),
-			lifetime: os.Getenv("COOKIE_LIFETIME"),
-			persist:  os.Getenv("COOKIE_PERSIST"),
-			secure:   os.Getenv("COOKIE_SECURE"),
-			domain:   os.Getenv("COOKIE_DOMAIN"),
-		},
-		sessionType: os.Getenv("SESSION_TYPE"),
-		database: databaseConfig{
-			dsn:      r.BuildDSN(),
-			database: os.Getenv("DATABASE_TYPE"),
-		},
-		redis: redisConfig{
-			host:     os.Getenv("REDIS_HOST"),
-			password: os.Getenv("REDIS_PASSWORD"),
-			prefix:   os.Getenv("REDIS_PREFIX"),
-		},
-	}
-
 	// create session
 	s := session.Session{
 		CookieLifetime: r.config.cookie.lifetime,
@@ -179,11 +219,28 @@ func (r *Rapidus) New(rootPath string) error {
 
 	r.Session = s.InitSession()
 
+	// keep queued mail in the store of MAIL_QUEUE, so it survives restarts
+	r.Mail.Store, r.closeMailQueue, err = r.OpenMailQueue()
+	if err != nil {
+		return err
+	}
+
 	// encryption key
 	r.EncryptionKey = os.Getenv("KEY")
+	previousKeys := r.previousKeys()
+	r.Encryption, err = r.createEncryption(previousKeys)
+	if err != nil {
+		return err
+	}
+	r.URLSigner = r.createURLSigner(previousKeys)
 
 	// create renderer
-	r.Render = render.Render{Session: r.Session}
+	r.createRenderer()
+
+	r.maintenance = &maintenanceFile{path: r.maintenancePath()}
+
+	// routes are created last, as middleware may be set up as soon as a route is added
+	r.Routes = r.routes().(*chi.Mux)
 
 	// listen for mail requests
 	go r.Mail.ListenForMail()
@@ -214,17 +271,53 @@ func (r *Rapidus) ListenAndServe() {
 		WriteTimeout: 600 * time.Second,
 	}
 
-	if r.DB.Pool != nil {
-		defer r.DB.Pool.Close()
+	// on an interrupt, requests in progress are finished before the connections are closed
+	stopped := make(chan struct{})
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			r.ErrorLog.Println(err)
+		}
+		close(stopped)
+	}()
+
+	r.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+		r.closeConnections()
+		r.InfoLog.Println("Server stopped")
+		return
+	}
+
+	r.closeConnections()
+	r.ErrorLog.Fatal(err)
+}
+
+// closeConnections closes the database, cache and mail queue connections the app opened
+func (r *Rapidus) closeConnections() {
+	if r.closeMailQueue != nil {
+		if err := r.closeMailQueue(); err != nil {
+			r.ErrorLog.Println(err)
+		}
 	}
 
 	if badgerConn != nil {
-		defer badgerConn.Close()
+		_ = badgerConn.Close()
 	}
 
-	r.InfoLog.Printf("Listening on port %s", os.Getenv("PORT"))
-	err := srv.ListenAndServe()
-	r.ErrorLog.Fatal(err)
+	if r.RedisClient != nil {
+		_ = r.RedisClient.Close()
+	}
+
+	if r.DB.Pool != nil {
+		_ = r.DB.Pool.Close()
+	}
 }
 
 func (r *Rapidus) checkDotEnv(path string) error {
@@ -245,11 +338,43 @@ func (r *Rapidus) startLoggers() (*log.Logger, *log.Logger) {
 	return infoLog, errorLog
 }
 
-//func (r *Rapidus) createRenderer() {
-//	myRenderer := render.Render{Session: r.Session}
-//
-//	r.Render = &myRenderer
-//}
+func (r *Rapidus) createRenderer() {
+	myRenderer := render.Render{
+		Renderer: r.config.renderer,
+		RootPath: r.RootPath,
+		Debug:    r.Debug,
+		Session:  r.Session,
+	}
+
+	switch strings.ToLower(r.config.renderer) {
+	case "go":
+		myRenderer.Engine = &render.GoRenderer{
+			RootPath: r.RootPath,
+			Debug:    r.Debug,
+		}
+	default:
+		myRenderer.Engine = &render.TemplRenderer{}
+	}
+
+	r.Render = myRenderer
+}
+
+// createEncryption creates the encryption from KEY and the previous keys, which is also used by
+// the encrypted database column types. Encryption is nil without KEY, and it is an error when a
+// key is not a valid 32 character key
+func (r *Rapidus) createEncryption(previousKeys [][]byte) (*encryption.Encryption, error) {
+	if r.EncryptionKey == "" {
+		return nil, nil
+	}
+
+	e, err := encryption.New([]byte(r.EncryptionKey), previousKeys...)
+	if err != nil {
+		return nil, fmt.Errorf("KEY or PREVIOUS_KEYS can't be used for encryption: %w", err)
+	}
+
+	encryption.SetDefault(e)
+	return e, nil
+}
 
 func (r *Rapidus) createMailer() mailer.Mail {
 	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
@@ -261,13 +386,23 @@ func (r *Rapidus) createMailer() mailer.Mail {
 		Username:    os.Getenv("SMTP_USERNAME"),
 		Password:    os.Getenv("SMTP_PASSWORD"),
 		Encryption:  os.Getenv("SMTP_ENCRYPTION"),
-		FromAddress: os.Getenv("MAIL_FROM_NAME"),
-		FromName:    os.Getenv("MAIL_FROM_ADDRESS"),
+		FromAddress: os.Getenv("MAIL_FROM_ADDRESS"),
+		FromName:    os.Getenv("MAIL_FROM_NAME"),
 		Jobs:        make(chan mailer.Message, 20),
 		Results:     make(chan mailer.Result, 20),
 		API:         os.Getenv("MAILER_API"),
 		APIKey:      os.Getenv("MAILER_KEY"),
 		APIUrl:      os.Getenv("MAILER_URL"),
+		DeadLetters: &mailer.MemoryDeadLetters{},
+	}
+
+	m.Workers, _ = strconv.Atoi(envOrDefault("MAIL_WORKERS", "2"))
+	m.MaxAttempts, _ = strconv.Atoi(envOrDefault("MAIL_MAX_ATTEMPTS", "3"))
+	m.Backoff, _ = time.ParseDuration(envOrDefault("MAIL_BACKOFF", "2s"))
+	m.Visibility, _ = time.ParseDuration(envOrDefault("MAIL_VISIBILITY", "5m"))
+
+	if m.API == "file" {
+		m.Driver = &mailer.FileDriver{Dir: r.RootPath + "/tmp/mail"}
 	}
 
 	return m
@@ -0,0 +1,56 @@
+package rapidus
+
+import (
+	"fmt"
+	"github.com/fouched/rapidus/ratelimit"
+	"net/http"
+)
+
+// RateLimit creates a rate limiting middleware, e.g. for a single route:
+//
+//	mux.With(app.RateLimit(ratelimit.Limiter{Name: "login", Limit: 5, Period: time.Minute})).Post("/users/login", ...)
+//
+// State is kept in redis when a redis client is configured, so that limits hold across instances,
+// otherwise in memory. Limited requests receive the 429 error page
+func (r *Rapidus) RateLimit(l ratelimit.Limiter) func(http.Handler) http.Handler {
+	if l.Store == nil {
+		l.Store = r.rateLimitStore()
+	}
+
+	if l.OnLimit == nil {
+		l.OnLimit = func(w http.ResponseWriter, req *http.Request) {
+			r.ErrorPage(w, req, http.StatusTooManyRequests)
+		}
+	}
+
+	if l.OnError == nil {
+		l.OnError = func(err error) {
+			r.ErrorLog.Println("rate limit:", err)
+		}
+	}
+
+	return l.Handler
+}
+
+// RateLimitByUser keys rate limits by the logged in user, falling back to the client IP
+// for guests. The session must be loaded, so use it on routes rather than globally
+func (r *Rapidus) RateLimitByUser(req *http.Request) string {
+	if r.Session.Exists(req.Context(), "userID") {
+		return fmt.Sprintf("user:%v", r.Session.Get(req.Context(), "userID"))
+	}
+	return "ip:" + ratelimit.KeyByIP(req)
+}
+
+func (r *Rapidus) rateLimitStore() ratelimit.Store {
+	if r.RedisClient != nil {
+		return &ratelimit.RedisStore{
+			Client: r.RedisClient,
+			Prefix: r.config.redis.prefix + ":",
+		}
+	}
+
+	if r.rateLimitMemory == nil {
+		r.rateLimitMemory = ratelimit.NewMemoryStore()
+	}
+	return r.rateLimitMemory
+}
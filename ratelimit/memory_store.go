@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps rate limit state in memory, so limits only apply per instance
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	calls   int
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	// sliding window: counts of the previous and current fixed window
+	windowStart time.Time
+	previous    int
+	current     int
+
+	// token bucket
+	tokens float64
+	last   time.Time
+
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryStore) Allow(_ context.Context, key string, limit int, period time.Duration, algorithm Algorithm) (Result, error) {
+	if period <= 0 {
+		return Result{}, errPeriod
+	}
+	if limit <= 0 {
+		return denyAll(period), nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	e, ok := m.entries[key]
+	if !ok {
+		e = &memoryEntry{windowStart: now, tokens: float64(limit), last: now}
+		m.entries[key] = e
+	}
+	e.expires = now.Add(2 * period)
+
+	if algorithm == TokenBucket {
+		return e.tokenBucket(now, limit, period), nil
+	}
+	return e.slidingWindow(now, limit, period), nil
+}
+
+// slidingWindow approximates a sliding window by weighting the previous fixed window
+// by how much of it still overlaps the sliding window
+func (e *memoryEntry) slidingWindow(now time.Time, limit int, period time.Duration) Result {
+	elapsed := now.Sub(e.windowStart)
+	if elapsed >= 2*period {
+		e.windowStart, e.previous, e.current = now, 0, 0
+		elapsed = 0
+	} else if elapsed >= period {
+		e.windowStart, e.previous, e.current = e.windowStart.Add(period), e.current, 0
+		elapsed -= period
+	}
+
+	weight := 1 - float64(elapsed)/float64(period)
+	count := int(math.Floor(float64(e.previous)*weight)) + e.current
+
+	res := Result{Limit: limit, Reset: period - elapsed}
+	if count >= limit {
+		res.RetryAfter = res.Reset
+		return res
+	}
+
+	e.current++
+	res.Allowed = true
+	res.Remaining = limit - count - 1
+	return res
+}
+
+func (e *memoryEntry) tokenBucket(now time.Time, limit int, period time.Duration) Result {
+	rate := float64(limit) / float64(period)
+	e.tokens = math.Min(float64(limit), e.tokens+float64(now.Sub(e.last))*rate)
+	e.last = now
+
+	res := Result{Limit: limit}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - e.tokens) / rate)
+	}
+
+	res.Remaining = int(e.tokens)
+	res.Reset = time.Duration((float64(limit) - e.tokens) / rate)
+	return res
+}
+
+// sweep removes expired entries every 1000 calls, so memory does not grow with every client seen
+func (m *MemoryStore) sweep(now time.Time) {
+	m.calls++
+	if m.calls < 1000 {
+		return
+	}
+	m.calls = 0
+
+	for key, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
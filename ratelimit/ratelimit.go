@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Algorithm int
+
+const (
+	// SlidingWindow allows Limit requests in any window of Period
+	SlidingWindow Algorithm = iota
+	// TokenBucket allows bursts of up to Limit requests, refilling at Limit per Period
+	TokenBucket
+)
+
+// errPeriod is returned by the stores for a period that is not positive
+var errPeriod = errors.New("ratelimit: period must be positive")
+
+// Store keeps the rate limit state. Use a RedisStore to share limits across instances. A limit of
+// 0 allows no requests
+type Store interface {
+	Allow(ctx context.Context, key string, limit int, period time.Duration, algorithm Algorithm) (Result, error)
+}
+
+// Result is the outcome of a single Allow call
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the full limit is available again
+	RetryAfter time.Duration // until the next request is allowed, when not allowed
+}
+
+// Limiter limits the requests per key. Each limiter keeps its own counters, so the same
+// client can have different limits on different routes
+type Limiter struct {
+	// Name identifies the counters of the limiter, and is required so they stay the same across
+	// restarts and instances, whatever order the limiters are created in
+	Name string
+	// Limit is the number of requests allowed per Period. A limit of 0 denies every request
+	Limit     int
+	Period    time.Duration
+	Algorithm Algorithm
+	Store     Store
+	// KeyFunc returns the key requests are counted by, KeyByIP by default
+	KeyFunc func(r *http.Request) string
+	// OnLimit writes the response for limited requests, a plain 429 by default
+	OnLimit func(w http.ResponseWriter, r *http.Request)
+	// OnError is called when the store fails, in which case the request is allowed
+	OnError func(err error)
+}
+
+// Handler is the rate limiting middleware. It panics when the limiter has no Name, a negative
+// Limit or no Period
+func (l *Limiter) Handler(next http.Handler) http.Handler {
+	if l.Name == "" {
+		panic("ratelimit: Limiter needs a Name")
+	}
+
+	if l.Limit < 0 {
+		panic(fmt.Sprintf("ratelimit: Limiter %s has a negative Limit", l.Name))
+	}
+
+	if l.Period <= 0 {
+		panic(fmt.Sprintf("ratelimit: Limiter %s needs a positive Period", l.Name))
+	}
+
+	if l.Store == nil {
+		l.Store = NewMemoryStore()
+	}
+
+	if l.KeyFunc == nil {
+		l.KeyFunc = KeyByIP
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.Name + ":" + l.KeyFunc(r)
+		res, err := l.Store.Allow(r.Context(), key, l.Limit, l.Period, l.Algorithm)
+		if err != nil {
+			if l.OnError != nil {
+				l.OnError(err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", seconds(res.Reset))
+
+		if !res.Allowed {
+			h.Set("Retry-After", seconds(res.RetryAfter))
+			if l.OnLimit != nil {
+				l.OnLimit(w, r)
+			} else {
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// denyAll is the result for a limit of 0, which does not change until the limit does
+func denyAll(period time.Duration) Result {
+	return Result{Reset: period, RetryAfter: period}
+}
+
+// KeyByIP keys requests by the client IP. Behind a proxy, use a middleware that sets the remote
+// address from the headers of trusted proxies only, such as Rapidus.RealIP
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// seconds rounds d up to whole seconds, as header values
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testStore(now *time.Time) *MemoryStore {
+	store := NewMemoryStore()
+	store.now = func() time.Time { return *now }
+	return store
+}
+
+func TestMemoryStore_SlidingWindow(t *testing.T) {
+	now := time.Now()
+	store := testStore(&now)
+
+	for i := 0; i < 3; i++ {
+		res, _ := store.Allow(context.Background(), "ip", 3, time.Minute, SlidingWindow)
+		if !res.Allowed {
+			t.Error("request", i, "not allowed")
+		}
+		if res.Remaining != 2-i {
+			t.Error("expected", 2-i, "remaining, got", res.Remaining)
+		}
+	}
+
+	res, _ := store.Allow(context.Background(), "ip", 3, time.Minute, SlidingWindow)
+	if res.Allowed {
+		t.Error("request over the limit allowed")
+	}
+	if res.RetryAfter <= 0 {
+		t.Error("no retry after for limited request")
+	}
+
+	// half way through the next window, half of the previous window still counts
+	now = now.Add(90 * time.Second)
+	res, _ = store.Allow(context.Background(), "ip", 3, time.Minute, SlidingWindow)
+	if !res.Allowed || res.Remaining != 1 {
+		t.Error("expected request to be allowed with one remaining, got", res)
+	}
+
+	res, _ = store.Allow(context.Background(), "other", 3, time.Minute, SlidingWindow)
+	if !res.Allowed {
+		t.Error("keys are not limited separately")
+	}
+}
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	now := time.Now()
+	store := testStore(&now)
+
+	for i := 0; i < 2; i++ {
+		res, _ := store.Allow(context.Background(), "ip", 2, time.Minute, TokenBucket)
+		if !res.Allowed {
+			t.Error("burst request", i, "not allowed")
+		}
+	}
+
+	res, _ := store.Allow(context.Background(), "ip", 2, time.Minute, TokenBucket)
+	if res.Allowed {
+		t.Error("request with empty bucket allowed")
+	}
+	if res.RetryAfter != 30*time.Second {
+		t.Error("expected retry after 30s, got", res.RetryAfter)
+	}
+
+	now = now.Add(30 * time.Second)
+	res, _ = store.Allow(context.Background(), "ip", 2, time.Minute, TokenBucket)
+	if !res.Allowed {
+		t.Error("request not allowed after refill")
+	}
+}
+
+func TestLimiter_Handler(t *testing.T) {
+	l := Limiter{Name: "login", Limit: 1, Period: time.Minute}
+	handler := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("POST", "/users/login", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Error("first request limited")
+	}
+	if w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Error("wrong rate limit headers", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Error("expected 429, got", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Error("wrong Retry-After", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestLimiter_Handler_Name(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a limiter without a name")
+		}
+	}()
+
+	l := Limiter{Limit: 1, Period: time.Minute}
+	l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func TestLimiter_Handler_invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		period time.Duration
+	}{
+		{"negative limit", -1, time.Minute},
+		{"no period", 5, 0},
+		{"negative period", 5, -time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic for an invalid limiter")
+				}
+			}()
+
+			l := Limiter{Name: "test", Limit: tt.limit, Period: tt.period}
+			l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		})
+	}
+}
+
+func TestLimiter_Handler_denyAll(t *testing.T) {
+	l := Limiter{Name: "closed", Limit: 0, Period: time.Minute}
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, algorithm := range []Algorithm{SlidingWindow, TokenBucket} {
+		l.Algorithm = algorithm
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+			t.Errorf("algorithm %d: expected every request to be denied, got %d %v", algorithm, w.Code, w.Header())
+		}
+	}
+}
+
+func TestMemoryStore_invalid(t *testing.T) {
+	store := NewMemoryStore()
+	for _, algorithm := range []Algorithm{SlidingWindow, TokenBucket} {
+		if _, err := store.Allow(context.Background(), "ip", 5, 0, algorithm); err == nil {
+			t.Errorf("algorithm %d: expected an error for a period of 0", algorithm)
+		}
+
+		res, err := store.Allow(context.Background(), "ip", 0, time.Minute, algorithm)
+		if err != nil || res.Allowed || res.RetryAfter != time.Minute {
+			t.Errorf("algorithm %d: expected a limit of 0 to deny, got %+v %v", algorithm, res, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// RedisStore keeps rate limit state in redis, so limits hold across instances. The scripts use
+// the time of the redis server, so the clocks of the instances don't need to agree
+type RedisStore struct {
+	Client *redis.Client
+	Prefix string
+}
+
+// slidingWindowScript keeps a sorted set of request times, and returns whether the request was
+// allowed, the number of requests in the window, the time of the oldest request and the time now
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local period = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - period)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, period)
+
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local first = now
+if oldest[2] then
+	first = tonumber(oldest[2])
+end
+return {allowed, count, first, now}
+`)
+
+// tokenBucketScript refills the bucket for the time passed since the last request, and
+// returns whether the request was allowed and the tokens left (in thousandths)
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local period = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local rate = limit / period
+
+local state = redis.call('HMGET', key, 'tokens', 'last')
+local tokens = tonumber(state[1]) or limit
+local last = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + (now - last) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', key, 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', key, period)
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+func (s *RedisStore) Allow(ctx context.Context, key string, limit int, period time.Duration, algorithm Algorithm) (Result, error) {
+	if period <= 0 {
+		return Result{}, errPeriod
+	}
+	if limit <= 0 {
+		return denyAll(period), nil
+	}
+
+	periodMs := period.Milliseconds()
+	key = s.Prefix + "ratelimit:" + key
+
+	res := Result{Limit: limit}
+
+	if algorithm == TokenBucket {
+		out, err := tokenBucketScript.Run(ctx, s.Client, []string{key}, periodMs, limit).Int64Slice()
+		if err != nil {
+			return res, err
+		}
+
+		tokens := float64(out[1]) / 1000
+		msPerToken := float64(periodMs) / float64(limit)
+		res.Allowed = out[0] == 1
+		res.Remaining = int(tokens)
+		res.Reset = time.Duration((float64(limit)-tokens)*msPerToken) * time.Millisecond
+		if !res.Allowed {
+			res.RetryAfter = time.Duration((1-tokens)*msPerToken) * time.Millisecond
+		}
+		return res, nil
+	}
+
+	// the member must be unique, or requests at the same time would only count once
+	member := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.FormatUint(rand.Uint64(), 36)
+	out, err := slidingWindowScript.Run(ctx, s.Client, []string{key}, periodMs, limit, member).Int64Slice()
+	if err != nil {
+		return res, err
+	}
+
+	res.Allowed = out[0] == 1
+	res.Remaining = max(limit-int(out[1]), 0)
+	res.Reset = time.Duration(out[2]+periodMs-out[3]) * time.Millisecond
+	if !res.Allowed {
+		res.RetryAfter = res.Reset
+	}
+	return res, nil
+}
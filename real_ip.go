@@ -0,0 +1,99 @@
+package rapidus
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxiesFromEnv reads TRUSTED_PROXIES, the IPs or CIDR ranges of the proxies in front of
+// the app, comma separated
+func trustedProxiesFromEnv() ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range envList("TRUSTED_PROXIES") {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: %w", item, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: %w", item, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// RealIP sets the remote address of a request to the client IP that a proxy passes in
+// X-Forwarded-For or X-Real-IP. The headers are only read from the proxies in TrustedProxies,
+// as anyone else could set them to pose as another client, e.g. to get around rate limits
+func (r *Rapidus) RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ip, ok := r.clientIP(req); ok {
+			req.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// clientIP returns the client IP passed by a trusted proxy. X-Forwarded-For is read from the
+// right, skipping the trusted proxies, as the entries to their left can be set by the client
+func (r *Rapidus) clientIP(req *http.Request) (netip.Addr, bool) {
+	if !r.trustedProxy(remoteIP(req.RemoteAddr)) {
+		return netip.Addr{}, false
+	}
+
+	if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		var client netip.Addr
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = ip.Unmap()
+			if !r.trustedProxy(client) {
+				break
+			}
+		}
+		return client, client.IsValid()
+	}
+
+	ip, err := netip.ParseAddr(strings.TrimSpace(req.Header.Get("X-Real-IP")))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+func (r *Rapidus) trustedProxy(ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	for _, proxy := range r.TrustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the IP of a remote address, which may have a port
+func remoteIP(remoteAddr string) netip.Addr {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ip.Unmap()
+}
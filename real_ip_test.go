@@ -0,0 +1,56 @@
+package rapidus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRapidus_RealIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+	proxies, err := trustedProxiesFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApp()
+	app.TrustedProxies = proxies
+
+	var tests = []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"direct", "1.2.3.4:5000", nil, "1.2.3.4:5000"},
+		{"spoofed forwarded for", "1.2.3.4:5000", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "1.2.3.4:5000"},
+		{"spoofed real ip", "1.2.3.4:5000", map[string]string{"X-Real-IP": "5.6.7.8"}, "1.2.3.4:5000"},
+		{"trusted proxy", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "5.6.7.8"}, "5.6.7.8"},
+		{"trusted real ip", "192.168.1.1:5000", map[string]string{"X-Real-IP": "5.6.7.8"}, "5.6.7.8"},
+		{"client set forwarded for", "10.1.2.3:5000", map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8, 10.0.0.2"}, "5.6.7.8"},
+		{"no header", "10.1.2.3:5000", nil, "10.1.2.3:5000"},
+	}
+
+	for _, e := range tests {
+		var got string
+		handler := app.RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.RemoteAddr
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = e.remoteAddr
+		for k, v := range e.headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "not-an-ip")
+	if _, err := trustedProxiesFromEnv(); err == nil {
+		t.Error("no error for an invalid TRUSTED_PROXIES entry")
+	}
+}
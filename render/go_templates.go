@@ -0,0 +1,99 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// GoRenderer renders html/template views from the views folder. A view named "home" is
+// read from views/home.page.tmpl, and is parsed together with all layouts
+// (views/layouts/*.layout.tmpl) and partials (views/partials/*.partial.tmpl).
+// Parsed templates are cached, unless Debug is set, in which case every request
+// reads the templates from disk so changes show up without a restart
+type GoRenderer struct {
+	RootPath  string
+	Debug     bool
+	Functions template.FuncMap
+
+	mu    sync.RWMutex
+	cache map[string]*template.Template
+}
+
+func (g *GoRenderer) Page(w http.ResponseWriter, r *http.Request, view interface{}, td *TemplateData) error {
+	name, ok := view.(string)
+	if !ok {
+		return fmt.Errorf("go renderer expects a template name, got %T", view)
+	}
+
+	t, err := g.template(name)
+	if err != nil {
+		return err
+	}
+
+	// render to a buffer first, so that a failing template does not send a partial page
+	var buf bytes.Buffer
+	if err = t.ExecuteTemplate(&buf, name+".page.tmpl", td); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (g *GoRenderer) template(name string) (*template.Template, error) {
+	if g.Debug {
+		return g.parse(name)
+	}
+
+	g.mu.RLock()
+	t, ok := g.cache[name]
+	g.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	t, err := g.parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	if g.cache == nil {
+		g.cache = make(map[string]*template.Template)
+	}
+	g.cache[name] = t
+	g.mu.Unlock()
+
+	return t, nil
+}
+
+func (g *GoRenderer) parse(name string) (*template.Template, error) {
+	views := filepath.Join(g.RootPath, "views")
+	page := filepath.Join(views, name+".page.tmpl")
+
+	t, err := template.New(filepath.Base(page)).Funcs(g.Functions).ParseFiles(page)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range []string{"layouts/*.layout.tmpl", "partials/*.partial.tmpl"} {
+		matches, err := filepath.Glob(filepath.Join(views, pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) > 0 {
+			t, err = t.ParseFiles(matches...)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,320 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/a-h/templ"
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// IsHTMX reports whether the request was made by htmx
+func IsHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// IsBoosted reports whether the request was made by an element using hx-boost.
+// Boosted requests swap the whole body, so they should receive the full page
+func IsBoosted(r *http.Request) bool {
+	return r.Header.Get("HX-Boosted") == "true"
+}
+
+// HTMX renders the full page for normal (and boosted) requests, and only the given fragments
+// for htmx requests. Fragments wrapped with OOB are swapped out of band by htmx, so a single
+// response can update several parts of the page
+func (ren *Render) HTMX(w http.ResponseWriter, r *http.Request, page templ.Component, fragments ...templ.Component) error {
+	// the response differs based on the header, so caches must take it into account
+	w.Header().Add("Vary", "HX-Request")
+
+	if IsHTMX(r) && !IsBoosted(r) && len(fragments) > 0 {
+		return ren.Template(w, r, templ.Join(fragments...))
+	}
+
+	return ren.Template(w, r, page)
+}
+
+// Fragments renders one or more components as a single response, typically a main
+// fragment followed by out of band components
+func (ren *Render) Fragments(w http.ResponseWriter, r *http.Request, components ...templ.Component) error {
+	return ren.Template(w, r, templ.Join(components...))
+}
+
+// OOB wraps a component in an element with the given id and an hx-swap-oob attribute.
+// Swap may be any htmx swap strategy, e.g. "outerHTML" or "beforeend"; "true" is used when empty
+func OOB(id, swap string, component templ.Component) templ.Component {
+	if swap == "" {
+		swap = "true"
+	}
+
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, `<div id="`+html.EscapeString(id)+`" hx-swap-oob="`+html.EscapeString(swap)+`">`)
+		if err != nil {
+			return err
+		}
+
+		err = component.Render(ctx, w)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(w, "</div>")
+		return err
+	})
+}
+
+// CSRFHeaders returns an hx-headers attribute containing the CSRF token of the current request.
+// Pages rendered with Page or Template get it on their body element automatically, so every htmx
+// request made from the page, including PUT, PATCH and DELETE, sends the token. Use it for pages
+// that are written some other way, e.g. <body { render.CSRFHeaders(ctx)... }>
+func CSRFHeaders(ctx context.Context) templ.Attributes {
+	token, _ := ctx.Value("CSRFToken").(string)
+	return templ.Attributes{"hx-headers": csrfHeaders(token)}
+}
+
+// HXHeaders is the html/template equivalent of CSRFHeaders, e.g. <body {{.HXHeaders}}>
+func (td *TemplateData) HXHeaders() template.HTMLAttr {
+	return template.HTMLAttr(`hx-headers="` + html.EscapeString(csrfHeaders(td.CSRFToken)) + `"`)
+}
+
+// csrfWriter adds the CSRF token to the body element of a page as it is written. Only the start of
+// a page is buffered, up to the end of its body start tag, and output that doesn't start like a
+// page, e.g. a fragment, is written as it is
+type csrfWriter struct {
+	http.ResponseWriter
+	token string
+	buf   bytes.Buffer
+	// done is set once the token was added, or can't be, after which writes go straight through
+	done bool
+}
+
+func (cw *csrfWriter) Write(b []byte) (int, error) {
+	if cw.done {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf.Write(b)
+	page := cw.buf.Bytes()
+	switch startsLikePage(page) {
+	case startUnknown:
+		return len(b), nil
+	case startFragment:
+		return len(b), cw.pass(page)
+	}
+
+	at, complete := findBody(page)
+	if !complete {
+		return len(b), nil
+	}
+	return len(b), cw.pass(insertCSRFHeaders(page, at, cw.token))
+}
+
+// Flush writes what is buffered, without the token when the body element has not been written
+// yet, so that pages are still sent progressively
+func (cw *csrfWriter) Flush() {
+	if !cw.done {
+		_ = cw.pass(cw.buf.Bytes())
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the ResponseWriter, for http.ResponseController
+func (cw *csrfWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close writes what is still buffered, which is a page without a body element
+func (cw *csrfWriter) close() error {
+	if cw.done {
+		return nil
+	}
+	return cw.pass(cw.buf.Bytes())
+}
+
+func (cw *csrfWriter) pass(b []byte) error {
+	cw.done = true
+	_, err := cw.ResponseWriter.Write(b)
+	cw.buf = bytes.Buffer{}
+	return err
+}
+
+type pageStart int
+
+const (
+	startUnknown pageStart = iota
+	startPage
+	startFragment
+)
+
+// pageStarts are what a page starts with, after any whitespace
+var pageStarts = [][]byte{[]byte("<!doctype"), []byte("<html"), []byte("<head"), []byte("<body")}
+
+// startsLikePage reports whether output is a page, a fragment, or too short to tell yet
+func startsLikePage(output []byte) pageStart {
+	output = bytes.TrimLeft(output, " \t\r\n")
+	result := startFragment
+	for _, start := range pageStarts {
+		n := min(len(output), len(start))
+		if !asciiEqualFold(output[:n], start[:n]) {
+			continue
+		}
+		if n == len(start) {
+			return startPage
+		}
+		result = startUnknown
+	}
+	return result
+}
+
+// findBody returns the index just after "<body" in the body start tag of a page, and whether the
+// whole start tag has been written. The index is -1 when there is no body start tag
+func findBody(page []byte) (int, bool) {
+	for i := 0; ; i++ {
+		n := bytes.IndexByte(page[i:], '<')
+		if n < 0 {
+			return -1, false
+		}
+		i += n
+		if len(page) < i+6 {
+			return -1, false
+		}
+		if asciiEqualFold(page[i+1:i+5], []byte("body")) && strings.IndexByte(" \t\r\n/>", page[i+5]) >= 0 {
+			return i + 5, bytes.IndexByte(page[i:], '>') >= 0
+		}
+	}
+}
+
+// asciiEqualFold reports whether a and b are equal, ignoring the case of ASCII letters. Unlike
+// bytes.ToLower it never changes the length of its input, so indexes stay the same
+func asciiEqualFold(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if 'A' <= x && x <= 'Z' {
+			x += 'a' - 'A'
+		}
+		if 'A' <= y && y <= 'Z' {
+			y += 'a' - 'A'
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// addCSRFHeaders adds an hx-headers attribute with the CSRF token to the body element of a page.
+// Fragments have no body element, and a body element that sets hx-headers itself is left alone
+func addCSRFHeaders(page []byte, token string) []byte {
+	at, complete := findBody(page)
+	if !complete {
+		return page
+	}
+	return insertCSRFHeaders(page, at, token)
+}
+
+// insertCSRFHeaders adds the hx-headers attribute at index at, just after "<body"
+func insertCSRFHeaders(page []byte, at int, token string) []byte {
+	end := bytes.IndexByte(page[at:], '>')
+	if token == "" || end < 0 || containsFold(page[at:at+end], []byte("hx-headers")) {
+		return page
+	}
+
+	attr := ` hx-headers="` + html.EscapeString(csrfHeaders(token)) + `"`
+	out := make([]byte, 0, len(page)+len(attr))
+	out = append(out, page[:at]...)
+	out = append(out, attr...)
+	return append(out, page[at:]...)
+}
+
+// containsFold reports whether s contains the lower case ASCII substr, in any case
+func containsFold(s, substr []byte) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if asciiEqualFold(s[i:i+len(substr)], substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfHeaders(token string) string {
+	out, _ := json.Marshal(map[string]string{"X-CSRF-Token": token})
+	return string(out)
+}
+
+// HXLocation does a client side redirect without a full page reload
+func HXLocation(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Location", url)
+}
+
+// HXRedirect does a client side redirect with a full page reload
+func HXRedirect(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Redirect", url)
+}
+
+// HXRefresh makes the client do a full refresh of the page
+func HXRefresh(w http.ResponseWriter) {
+	w.Header().Set("HX-Refresh", "true")
+}
+
+// HXPushURL pushes a new url into the browser history
+func HXPushURL(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Push-Url", url)
+}
+
+// HXReplaceURL replaces the current url in the browser location bar
+func HXReplaceURL(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Replace-Url", url)
+}
+
+// HXRetarget sets the CSS selector of the element the response is swapped into
+func HXRetarget(w http.ResponseWriter, selector string) {
+	w.Header().Set("HX-Retarget", selector)
+}
+
+// HXReselect sets the CSS selector of the part of the response that is swapped in
+func HXReselect(w http.ResponseWriter, selector string) {
+	w.Header().Set("HX-Reselect", selector)
+}
+
+// HXReswap sets how the response is swapped in, e.g. "outerHTML"
+func HXReswap(w http.ResponseWriter, swap string) {
+	w.Header().Set("HX-Reswap", swap)
+}
+
+// HXTrigger triggers one or more client side events as soon as the response is received
+func HXTrigger(w http.ResponseWriter, events ...string) {
+	w.Header().Set("HX-Trigger", strings.Join(events, ", "))
+}
+
+// HXTriggerWithDetail triggers client side events, passing each event the given detail
+func HXTriggerWithDetail(w http.ResponseWriter, events map[string]interface{}) error {
+	return setJSONHeader(w, "HX-Trigger", events)
+}
+
+// HXTriggerAfterSettle triggers client side events after the settle step
+func HXTriggerAfterSettle(w http.ResponseWriter, events ...string) {
+	w.Header().Set("HX-Trigger-After-Settle", strings.Join(events, ", "))
+}
+
+// HXTriggerAfterSwap triggers client side events after the swap step
+func HXTriggerAfterSwap(w http.ResponseWriter, events ...string) {
+	w.Header().Set("HX-Trigger-After-Swap", strings.Join(events, ", "))
+}
+
+func setJSONHeader(w http.ResponseWriter, key string, value interface{}) error {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set(key, string(out))
+	return nil
+}
@@ -0,0 +1,173 @@
+package render
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"github.com/justinas/nosurf"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func text(s string) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	})
+}
+
+func TestRender_HTMX(t *testing.T) {
+	var tests = []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"normal request", nil, "page"},
+		{"htmx request", map[string]string{"HX-Request": "true"}, `fragment<div id="count" hx-swap-oob="true">3</div>`},
+		{"boosted request", map[string]string{"HX-Request": "true", "HX-Boosted": "true"}, "page"},
+	}
+
+	for _, e := range tests {
+		r := getSessionRequest(t)
+		for k, v := range e.headers {
+			r.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		err := testRender.HTMX(w, r, text("page"), text("fragment"), OOB("count", "", text("3")))
+		if err != nil {
+			t.Error(e.name, err)
+		}
+
+		if w.Body.String() != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, w.Body.String())
+		}
+
+		if w.Header().Get("Vary") != "HX-Request" {
+			t.Error(e.name, "Vary header not set")
+		}
+	}
+}
+
+func TestCSRFHeaders(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "CSRFToken", "abc")
+	attrs := CSRFHeaders(ctx)
+	if attrs["hx-headers"] != `{"X-CSRF-Token":"abc"}` {
+		t.Error("wrong hx-headers attribute:", attrs["hx-headers"])
+	}
+
+	td := TemplateData{CSRFToken: "abc"}
+	if !strings.Contains(string(td.HXHeaders()), "X-CSRF-Token&#34;:&#34;abc") {
+		t.Error("wrong hx-headers attribute:", td.HXHeaders())
+	}
+}
+
+func TestHXHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	HXRedirect(w, "/users/login")
+	HXRetarget(w, "#errors")
+	HXPushURL(w, "/items/1")
+	HXTrigger(w, "saved", "closeModal")
+	_ = HXTriggerWithDetail(w, map[string]interface{}{"saved": map[string]int{"id": 1}})
+
+	if w.Header().Get("HX-Redirect") != "/users/login" {
+		t.Error("HX-Redirect not set")
+	}
+
+	if w.Header().Get("HX-Retarget") != "#errors" {
+		t.Error("HX-Retarget not set")
+	}
+
+	if w.Header().Get("HX-Push-Url") != "/items/1" {
+		t.Error("HX-Push-Url not set")
+	}
+
+	if w.Header().Get("HX-Trigger") != `{"saved":{"id":1}}` {
+		t.Error("wrong HX-Trigger:", w.Header().Get("HX-Trigger"))
+	}
+}
+
+func TestAddCSRFHeaders(t *testing.T) {
+	var tests = []struct {
+		name     string
+		page     string
+		expected string
+	}{
+		{"page", `<html><BODY class="a"><p>x</p></BODY></html>`, `<html><BODY hx-headers="{&#34;X-CSRF-Token&#34;:&#34;abc&#34;}" class="a"><p>x</p></BODY></html>`},
+		{"plain body", `<body>x</body>`, `<body hx-headers="{&#34;X-CSRF-Token&#34;:&#34;abc&#34;}">x</body>`},
+		{"fragment", `<p>x</p>`, `<p>x</p>`},
+		{"other element", `<bodyguard>x</bodyguard>`, `<bodyguard>x</bodyguard>`},
+		{"own hx-headers", `<body hx-headers='{"X-CSRF-Token":"mine"}'>x</body>`, `<body hx-headers='{"X-CSRF-Token":"mine"}'>x</body>`},
+		{"non-ascii before body", "<html><title>İstanbul \xff</title><body>x</body>", "<html><title>İstanbul \xff</title><body hx-headers=\"{&#34;X-CSRF-Token&#34;:&#34;abc&#34;}\">x</body>"},
+		{"own HX-HEADERS", `<body HX-HEADERS='{}'>x</body>`, `<body HX-HEADERS='{}'>x</body>`},
+	}
+
+	for _, e := range tests {
+		if got := string(addCSRFHeaders([]byte(e.page), "abc")); got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+
+	if got := string(addCSRFHeaders([]byte(`<body>x</body>`), "")); got != `<body>x</body>` {
+		t.Error("attribute added without a token:", got)
+	}
+}
+
+func TestCSRFWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	cw := &csrfWriter{ResponseWriter: w, token: "abc"}
+
+	_, _ = io.WriteString(cw, "<!DOCTYPE html><html><head>")
+	_, _ = io.WriteString(cw, "</head><body")
+	if w.Body.Len() != 0 {
+		t.Error("page written before its body element:", w.Body.String())
+	}
+
+	_, _ = io.WriteString(cw, ` class="a"><p>`)
+	expected := `<!DOCTYPE html><html><head></head><body hx-headers="{&#34;X-CSRF-Token&#34;:&#34;abc&#34;}" class="a"><p>`
+	if w.Body.String() != expected {
+		t.Error("page not written once its body element is:", w.Body.String())
+	}
+
+	_, _ = io.WriteString(cw, "streamed")
+	http.NewResponseController(cw).Flush()
+	if w.Body.String() != expected+"streamed" || !w.Flushed {
+		t.Error("rest of the page not streamed:", w.Body.String())
+	}
+
+	// fragments are written as they are, and a flush sends a page without waiting for its body
+	w = httptest.NewRecorder()
+	cw = &csrfWriter{ResponseWriter: w, token: "abc"}
+	_, _ = io.WriteString(cw, "  <div>")
+	if w.Body.String() != "  <div>" {
+		t.Error("fragment not written straight away:", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	cw = &csrfWriter{ResponseWriter: w, token: "abc"}
+	_, _ = io.WriteString(cw, "<html><head>")
+	cw.Flush()
+	_, _ = io.WriteString(cw, "<body>")
+	if w.Body.String() != "<html><head><body>" || !w.Flushed {
+		t.Error("flush did not send the start of the page:", w.Body.String())
+	}
+}
+
+func TestRender_Template_CSRFHeaders(t *testing.T) {
+	var body string
+	handler := nosurf.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, _ := testSession.Load(r.Context(), "")
+		rec := httptest.NewRecorder()
+		if err := testRender.Template(rec, r.WithContext(ctx), text(`<body><button hx-delete="/items/1">Delete</button></body>`)); err != nil {
+			t.Error(err)
+		}
+		body = rec.Body.String()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if !strings.HasPrefix(body, `<body hx-headers="{&#34;X-CSRF-Token&#34;:&#34;`) {
+		t.Error("hx-headers not added to the body:", body)
+	}
+}
@@ -8,17 +8,102 @@ import (
 	"net/http"
 )
 
+// Renderer is implemented by every template engine Rapidus can render with.
+// The view is engine specific, e.g. a templ.Component for templ or a template name for go
+type Renderer interface {
+	Page(w http.ResponseWriter, r *http.Request, view interface{}, td *TemplateData) error
+}
+
 type Render struct {
-	Session *scs.SessionManager
+	Renderer string
+	RootPath string
+	Debug    bool
+	Session  *scs.SessionManager
+	Engine   Renderer
 }
 
+// TemplateData holds the default data that is made available to every view, regardless of engine
+type TemplateData struct {
+	IsAuthenticated bool
+	CSRFToken       string
+	Nonce           string
+	Success         string
+	Warning         string
+	Error           string
+	Data            interface{}
+}
+
+// Template renders a templ component, regardless of the configured renderer
 func (ren *Render) Template(w http.ResponseWriter, r *http.Request, template templ.Component) error {
+	return ren.render(w, r, &TemplRenderer{}, template, nil)
+}
+
+// Page renders a view using the configured engine. Data is passed to the view as TemplateData.Data
+func (ren *Render) Page(w http.ResponseWriter, r *http.Request, view interface{}, data interface{}) error {
+	engine := ren.Engine
+	if engine == nil {
+		engine = &TemplRenderer{}
+	}
+
+	return ren.render(w, r, engine, view, data)
+}
+
+// render renders a view, and adds the CSRF token to the body element of a full page, so that every
+// htmx request made from the page sends it
+func (ren *Render) render(w http.ResponseWriter, r *http.Request, engine Renderer, view interface{}, data interface{}) error {
+	td := ren.defaultData(r, data)
+	if td.CSRFToken == "" {
+		return engine.Page(w, r, view, td)
+	}
+
+	cw := &csrfWriter{ResponseWriter: w, token: td.CSRFToken}
+	if err := engine.Page(cw, r, view, td); err != nil {
+		return err
+	}
+	return cw.close()
+}
+
+// defaultData pops flash messages from the session and adds the values every view needs
+func (ren *Render) defaultData(r *http.Request, data interface{}) *TemplateData {
+	td := &TemplateData{
+		CSRFToken: nosurf.Token(r),
+		Nonce:     templ.GetNonce(r.Context()),
+		Data:      data,
+	}
+
+	if ren.Session != nil {
+		td.IsAuthenticated = ren.Session.Exists(r.Context(), "userID")
+		td.Success = ren.Session.PopString(r.Context(), "success")
+		td.Warning = ren.Session.PopString(r.Context(), "warning")
+		td.Error = ren.Session.PopString(r.Context(), "error")
+	}
+
+	return td
+}
+
+// templateContext creates a context and sets value(s) that will be available to all templ templates
+func templateContext(ctx context.Context, td *TemplateData) context.Context {
+	ctx = context.WithValue(ctx, "CSRFToken", td.CSRFToken)
+	ctx = context.WithValue(ctx, "nonce", td.Nonce)
+	ctx = context.WithValue(ctx, "success", td.Success)
+	ctx = context.WithValue(ctx, "warning", td.Warning)
+	ctx = context.WithValue(ctx, "error", td.Error)
+	ctx = context.WithValue(ctx, "isAuthenticated", td.IsAuthenticated)
+
+	return ctx
+}
+
+// ErrorPage writes the status code and renders a templ error page. The status is available to the
+// component as ctx.Value("errorStatus"). Flash messages are left in the session for the next page
+func (ren *Render) ErrorPage(w http.ResponseWriter, r *http.Request, status int, page templ.Component) error {
+	ctx := templateContext(r.Context(), &TemplateData{
+		CSRFToken: nosurf.Token(r),
+		Nonce:     templ.GetNonce(r.Context()),
+	})
+	ctx = context.WithValue(ctx, "errorStatus", status)
 
-	// Create a context and set value(s) that will be available to all templates
-	ctx := context.WithValue(r.Context(), "CSRFToken", nosurf.Token(r))
-	ctx = context.WithValue(ctx, "success", ren.Session.PopString(r.Context(), "success"))
-	ctx = context.WithValue(ctx, "warning", ren.Session.PopString(r.Context(), "warning"))
-	ctx = context.WithValue(ctx, "error", ren.Session.PopString(r.Context(), "error"))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
 
-	return template.Render(ctx, w)
+	return page.Render(ctx, w)
 }
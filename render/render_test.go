@@ -0,0 +1,117 @@
+package render
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func getSessionRequest(t *testing.T) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	ctx, err := testSession.Load(r.Context(), r.Header.Get("X-Session"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r.WithContext(ctx)
+}
+
+func TestRender_Page(t *testing.T) {
+	testRender.Engine = &GoRenderer{RootPath: testRender.RootPath}
+
+	r := getSessionRequest(t)
+	testSession.Put(r.Context(), "success", "saved")
+
+	w := httptest.NewRecorder()
+	err := testRender.Page(w, r, "home", "hello")
+	if err != nil {
+		t.Error(err)
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, "<h1>hello</h1>") {
+		t.Error("data not rendered in go template:", body)
+	}
+
+	if !strings.Contains(body, `<p class="success">saved</p>`) {
+		t.Error("flash message not rendered from partial:", body)
+	}
+
+	err = testRender.Page(httptest.NewRecorder(), getSessionRequest(t), "no-such-page", nil)
+	if err == nil {
+		t.Error("no error rendering a page that does not exist")
+	}
+}
+
+func TestGoRenderer_Cache(t *testing.T) {
+	g := &GoRenderer{RootPath: "./testdata"}
+	err := g.Page(httptest.NewRecorder(), getSessionRequest(t), "home", &TemplateData{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := g.cache["home"]; !ok {
+		t.Error("template not cached when not in debug mode")
+	}
+
+	g = &GoRenderer{RootPath: "./testdata", Debug: true}
+	err = g.Page(httptest.NewRecorder(), getSessionRequest(t), "home", &TemplateData{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(g.cache) > 0 {
+		t.Error("template cached in debug mode")
+	}
+}
+
+func TestRender_Template(t *testing.T) {
+	testRender.Engine = &GoRenderer{RootPath: testRender.RootPath}
+
+	component := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, ctx.Value("success").(string))
+		return err
+	})
+
+	r := getSessionRequest(t)
+	testSession.Put(r.Context(), "success", "templ flash")
+
+	w := httptest.NewRecorder()
+	err := testRender.Template(w, r, component)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if w.Body.String() != "templ flash" {
+		t.Error("wrong output from templ component:", w.Body.String())
+	}
+
+	err = testRender.Engine.Page(httptest.NewRecorder(), r, component, &TemplateData{})
+	if err == nil {
+		t.Error("no error passing a templ component to the go renderer")
+	}
+}
+
+func TestRender_Nonce(t *testing.T) {
+	component := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, ctx.Value("nonce").(string))
+		return err
+	})
+
+	r := getSessionRequest(t)
+	r = r.WithContext(templ.WithNonce(r.Context(), "abc123"))
+
+	w := httptest.NewRecorder()
+	err := testRender.Template(w, r, component)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if w.Body.String() != "abc123" {
+		t.Error("nonce not available to templ component, got", w.Body.String())
+	}
+}
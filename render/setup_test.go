@@ -0,0 +1,21 @@
+package render
+
+import (
+	"github.com/alexedwards/scs/v2"
+	"os"
+	"testing"
+)
+
+var testSession *scs.SessionManager
+
+var testRender = Render{
+	Renderer: "go",
+	RootPath: "./testdata",
+}
+
+func TestMain(m *testing.M) {
+	testSession = scs.New()
+	testRender.Session = testSession
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,19 @@
+package render
+
+import (
+	"fmt"
+	"github.com/a-h/templ"
+	"net/http"
+)
+
+// TemplRenderer renders templ components
+type TemplRenderer struct{}
+
+func (t *TemplRenderer) Page(w http.ResponseWriter, r *http.Request, view interface{}, td *TemplateData) error {
+	component, ok := view.(templ.Component)
+	if !ok {
+		return fmt.Errorf("templ renderer expects a templ.Component, got %T", view)
+	}
+
+	return component.Render(templateContext(r.Context(), td), w)
+}
@@ -0,0 +1,45 @@
+package rapidus
+
+import (
+	"errors"
+	"github.com/fouched/rapidus/pagecache"
+	"net/http"
+)
+
+// CacheResponses creates a middleware that caches full responses in the configured cache, e.g.
+//
+//	mux.With(app.CacheResponses(pagecache.Options{TTL: 10 * time.Minute})).Get("/", handlers.Home)
+//
+// Logged in users bypass the cache unless Bypass is set. Tag responses with pagecache.Tag,
+// and purge them with PurgeResponses
+func (r *Rapidus) CacheResponses(opts pagecache.Options) func(http.Handler) http.Handler {
+	if r.Cache == nil {
+		r.ErrorLog.Println("response caching requires CACHE to be set in .env")
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	opts.Cache = r.Cache
+	if opts.Session == nil {
+		opts.Session = r.Session
+	}
+
+	if opts.Bypass == nil {
+		opts.Bypass = func(req *http.Request) bool {
+			return r.Session.Exists(req.Context(), "userID")
+		}
+	}
+
+	return pagecache.Handler(opts)
+}
+
+// PurgeResponses removes every cached response tagged with one of tags, for responses cached
+// with the default prefix
+func (r *Rapidus) PurgeResponses(tags ...string) error {
+	if r.Cache == nil {
+		return errors.New("response caching requires CACHE to be set in .env")
+	}
+
+	return pagecache.Purge(r.Cache, "pagecache", tags...)
+}
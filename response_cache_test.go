@@ -0,0 +1,133 @@
+package rapidus
+
+import (
+	"context"
+	"fmt"
+	"github.com/a-h/templ"
+	"github.com/fouched/rapidus/cache"
+	"github.com/fouched/rapidus/pagecache"
+	"github.com/fouched/rapidus/render"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// mapCache is a cache.Cache in a map
+type mapCache map[string]interface{}
+
+func (m mapCache) Has(key string) (bool, error) {
+	if _, ok := m[key]; !ok {
+		return false, cache.ErrNotFound
+	}
+	return true, nil
+}
+
+func (m mapCache) Get(key string) (interface{}, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m mapCache) Set(key string, value interface{}, _ ...int) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapCache) Forget(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m mapCache) EmptyByMatch(prefix string) error {
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			delete(m, k)
+		}
+	}
+	return nil
+}
+
+func (m mapCache) Empty() error {
+	return m.EmptyByMatch("")
+}
+
+// TestRapidus_CacheResponses_secrets runs a cached page through the middleware of the routes, to
+// check that every visitor gets the nonce of its CSP header and its own CSRF token
+func TestRapidus_CacheResponses_secrets(t *testing.T) {
+	app := newTestApp()
+	app.Cache = mapCache{}
+	app.Security = SecurityConfig{ContentSecurityPolicy: defaultCSP}
+
+	page := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := fmt.Fprintf(w, `<html><head><script nonce="%s"></script></head><body><form method="post">`+
+			`<input type="hidden" name="csrf_token" value="%s"></form></body></html>`, templ.GetNonce(ctx), ctx.Value("CSRFToken"))
+		return err
+	})
+
+	calls := 0
+	cached := app.CacheResponses(pagecache.Options{Bypass: func(*http.Request) bool { return false }})
+	handler := app.SecurityHeaders(app.NoSurf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			return
+		}
+		cached(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_ = app.Render.Template(w, r, page)
+		})).ServeHTTP(w, r)
+	})))
+	app.Render = render.Render{}
+
+	nonce := regexp.MustCompile(`nonce="([^"]+)"`)
+	token := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+	hxToken := regexp.MustCompile(`hx-headers="{&#34;X-CSRF-Token&#34;:&#34;([^&]+)&#34;}"`)
+
+	var tokens []string
+	for _, expected := range []string{"MISS", "HIT", "HIT"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		body := w.Body.String()
+
+		if w.Header().Get("X-Cache") != expected {
+			t.Fatalf("expected %s, got %q", expected, w.Header().Get("X-Cache"))
+		}
+
+		csp := w.Header().Get("Content-Security-Policy")
+		if m := nonce.FindStringSubmatch(body); m == nil || !strings.Contains(csp, "'nonce-"+m[1]+"'") {
+			t.Errorf("%s: nonce of the page is not the nonce of the CSP %q: %s", expected, csp, body)
+		}
+
+		m, hx := token.FindStringSubmatch(body), hxToken.FindStringSubmatch(body)
+		if m == nil || hx == nil || m[1] != hx[1] {
+			t.Fatalf("%s: expected the same token in the form and hx-headers: %s", expected, body)
+		}
+		for _, other := range tokens {
+			if m[1] == other {
+				t.Errorf("%s: the CSRF token of another visitor was served", expected)
+			}
+		}
+		tokens = append(tokens, m[1])
+
+		// the token is valid for the CSRF cookie this visitor got
+		form := url.Values{"csrf_token": {m[1]}}
+		req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range w.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		pw := httptest.NewRecorder()
+		handler.ServeHTTP(pw, req)
+		if pw.Code != http.StatusOK {
+			t.Errorf("%s: the token of the page is rejected, got %d", expected, pw.Code)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected the page to be rendered once, got %d", calls)
+	}
+}
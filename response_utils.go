@@ -7,7 +7,16 @@ import (
 
 var t toolkit.Tools
 
-func (r *Rapidus) ReadJSON(w http.ResponseWriter, req *http.Request, data interface{}) error {
+// ReadJSON decodes a JSON request body into data. When a schema name is given the body is
+// validated against that JSON schema first, and a *SchemaError is returned if it does not match
+func (r *Rapidus) ReadJSON(w http.ResponseWriter, req *http.Request, data interface{}, schemaName ...string) error {
+	if len(schemaName) > 0 {
+		err := r.validateBody(w, req, schemaName[0])
+		if err != nil {
+			return err
+		}
+	}
+
 	return t.ReadJSON(w, req, data)
 }
 
@@ -23,6 +32,8 @@ func (r *Rapidus) DownloadStaticFile(w http.ResponseWriter, req *http.Request, p
 	t.DownloadStaticFile(w, req, pathName, displayName)
 }
 
+// ErrorStatus writes a plain text error response for status. Use ErrorStatusFor to show the pages
+// registered with SetErrorPage, or problem details to API clients
 func (r *Rapidus) ErrorStatus(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
@@ -42,3 +53,25 @@ func (r *Rapidus) ErrorUnauthorized(w http.ResponseWriter) {
 func (r *Rapidus) ErrorForbidden(w http.ResponseWriter) {
 	r.ErrorStatus(w, http.StatusForbidden)
 }
+
+// ErrorStatusFor writes an error response for status with ErrorPage, so the pages registered with
+// SetErrorPage are shown, and clients asking for JSON or XML receive problem details
+func (r *Rapidus) ErrorStatusFor(w http.ResponseWriter, req *http.Request, status int) {
+	r.ErrorPage(w, req, status)
+}
+
+func (r *Rapidus) Error404For(w http.ResponseWriter, req *http.Request) {
+	r.ErrorStatusFor(w, req, http.StatusNotFound)
+}
+
+func (r *Rapidus) Error500For(w http.ResponseWriter, req *http.Request) {
+	r.ErrorStatusFor(w, req, http.StatusInternalServerError)
+}
+
+func (r *Rapidus) ErrorUnauthorizedFor(w http.ResponseWriter, req *http.Request) {
+	r.ErrorStatusFor(w, req, http.StatusUnauthorized)
+}
+
+func (r *Rapidus) ErrorForbiddenFor(w http.ResponseWriter, req *http.Request) {
+	r.ErrorStatusFor(w, req, http.StatusForbidden)
+}
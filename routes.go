@@ -1,25 +1,48 @@
 package rapidus
 
 import (
+	"github.com/fouched/rapidus/compress"
+	"github.com/fouched/rapidus/cors"
+	"github.com/fouched/rapidus/ratelimit"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"net/http"
+	"time"
 )
 
 func (r *Rapidus) routes() http.Handler {
 	mux := chi.NewRouter()
 	addMiddleware(mux, r)
 
+	mux.NotFound(func(w http.ResponseWriter, req *http.Request) {
+		r.ErrorPage(w, req, http.StatusNotFound)
+	})
+	mux.MethodNotAllowed(func(w http.ResponseWriter, req *http.Request) {
+		r.ErrorPage(w, req, http.StatusMethodNotAllowed)
+	})
+
+	if r.Security.CSPReportURI != "" {
+		limit := r.RateLimit(ratelimit.Limiter{Name: "csp-report", Limit: 60, Period: time.Minute})
+		mux.With(limit).Post(r.Security.CSPReportURI, r.CSPReportHandler)
+	}
+
 	return mux
 }
 
 func addMiddleware(mux *chi.Mux, r *Rapidus) {
 	mux.Use(middleware.RequestID)
-	mux.Use(middleware.RealIP)
-	mux.Use(middleware.Recoverer)
-	//if r.Debug {
-	//	mux.Use(middleware.Logger)
-	//}
+	mux.Use(r.RealIP)
+	mux.Use(r.SecurityHeaders)
+	mux.Use(r.AccessLog)
+	if opts, ok := compressOptionsFromEnv(); ok {
+		mux.Use(compress.Handler(opts))
+	}
+	mux.Use(r.Recoverer)
+	mux.Use(r.MaintenanceMode)
+
+	if opts := corsOptionsFromEnv(); len(opts.AllowedOrigins) > 0 {
+		mux.Use(cors.Handler(opts))
+	}
 
 	mux.Use(r.SessionLoad)
 	mux.Use(r.NoSurf)
@@ -0,0 +1,131 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/xeipuuv/gojsonschema"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Registry holds JSON schemas, compiled once when they are added
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]*gojsonschema.Schema
+}
+
+// ErrInvalidJSON is returned when the document to validate is not JSON at all
+var ErrInvalidJSON = errors.New("body contains malformed JSON")
+
+func New() *Registry {
+	return &Registry{schemas: make(map[string]*gojsonschema.Schema)}
+}
+
+// Add compiles a schema and registers it under name
+func (r *Registry) Add(name string, schema []byte) error {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return fmt.Errorf("schema %s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	r.schemas[name] = compiled
+	r.mu.Unlock()
+
+	return nil
+}
+
+// AddFile compiles the schema in a file and registers it under name
+func (r *Registry) AddFile(name, file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return r.Add(name, content)
+}
+
+// LoadFS registers every .json file in dir, e.g. of an embed.FS, named after the file
+// without its extension: dir/user_create.json is registered as user_create
+func (r *Registry) LoadFS(fsys fs.FS, dir string) error {
+	matches, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		content, err := fs.ReadFile(fsys, match)
+		if err != nil {
+			return err
+		}
+
+		err = r.Add(strings.TrimSuffix(path.Base(match), ".json"), content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Has reports whether a schema is registered under name
+func (r *Registry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.schemas[name]
+	return ok
+}
+
+// Validate validates a JSON document against the named schema. The returned map holds
+// an error message per invalid field, and is empty when the document is valid
+func (r *Registry) Validate(name string, document []byte) (map[string]string, error) {
+	r.mu.RLock()
+	compiled, ok := r.schemas[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no JSON schema named %s", name)
+	}
+
+	result, err := compiled.Validate(gojsonschema.NewBytesLoader(document))
+	if err != nil {
+		return nil, ErrInvalidJSON
+	}
+
+	errs := make(map[string]string)
+	for _, e := range result.Errors() {
+		field := fieldName(e)
+		if _, exists := errs[field]; !exists {
+			errs[field] = e.Description()
+		}
+	}
+
+	return errs, nil
+}
+
+// fieldName returns the field a validation error belongs to. Missing required
+// properties are reported against the property, not the object that should contain it
+func fieldName(e gojsonschema.ResultError) string {
+	field := e.Field()
+	if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		field = ""
+	}
+
+	if e.Type() == "required" {
+		if property, ok := e.Details()["property"].(string); ok {
+			if field == "" {
+				return property
+			}
+			return field + "." + property
+		}
+	}
+
+	if field == "" {
+		return gojsonschema.STRING_ROOT_SCHEMA_PROPERTY
+	}
+	return field
+}
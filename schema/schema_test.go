@@ -0,0 +1,67 @@
+package schema
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRegistry_LoadFS(t *testing.T) {
+	r := New()
+	err := r.LoadFS(os.DirFS("./testdata"), "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.Has("user") {
+		t.Error("user schema not registered")
+	}
+
+	err = r.Add("broken", []byte(`{"type": 5}`))
+	if err == nil {
+		t.Error("no error adding an invalid schema")
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	r := New()
+	_ = r.AddFile("user", "./testdata/schemas/user.json")
+
+	var tests = []struct {
+		name     string
+		document string
+		errors   []string
+	}{
+		{"valid", `{"email": "me@here.com", "age": 21, "address": {"city": "Cape Town"}}`, nil},
+		{"missing fields", `{}`, []string{"email", "age"}},
+		{"invalid fields", `{"email": "not an email", "age": 12}`, []string{"email", "age"}},
+		{"nested", `{"email": "me@here.com", "age": 21, "address": {}}`, []string{"address.city"}},
+		{"unknown field", `{"email": "me@here.com", "age": 21, "admin": true}`, []string{"(root)"}},
+	}
+
+	for _, e := range tests {
+		errs, err := r.Validate("user", []byte(e.document))
+		if err != nil {
+			t.Error(e.name, err)
+		}
+
+		if len(errs) != len(e.errors) {
+			t.Errorf("%s: expected %d errors but got %v", e.name, len(e.errors), errs)
+		}
+
+		for _, field := range e.errors {
+			if _, ok := errs[field]; !ok {
+				t.Errorf("%s: no error for %s in %v", e.name, field, errs)
+			}
+		}
+	}
+
+	_, err := r.Validate("user", []byte(`{"email":`))
+	if err != ErrInvalidJSON {
+		t.Error("expected ErrInvalidJSON, got", err)
+	}
+
+	_, err = r.Validate("unknown", []byte(`{}`))
+	if err == nil {
+		t.Error("no error validating against an unknown schema")
+	}
+}
@@ -0,0 +1,137 @@
+package rapidus
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/a-h/templ"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultCSP = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+	"img-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'self'"
+
+// SecurityConfig holds the security headers sent with every response.
+// Headers with an empty value are not sent
+type SecurityConfig struct {
+	// ContentSecurityPolicy may contain {nonce}, which is replaced with a new nonce on every request
+	ContentSecurityPolicy     string
+	CSPReportOnly             bool
+	CSPReportURI              string
+	FrameOptions              string
+	ReferrerPolicy            string
+	PermissionsPolicy         string
+	ContentTypeNosniff        bool
+	CrossOriginOpenerPolicy   string
+	CrossOriginEmbedderPolicy string
+}
+
+// securityConfigFromEnv reads the security headers from .env, using safe defaults for unset values.
+// The content security policy is only reported until CSP_REPORT_ONLY is false, so pages with inline
+// scripts or styles keep working until the policy is enforced
+func securityConfigFromEnv() SecurityConfig {
+	reportOnly, err := strconv.ParseBool(os.Getenv("CSP_REPORT_ONLY"))
+	if err != nil {
+		reportOnly = true
+	}
+
+	return SecurityConfig{
+		ContentSecurityPolicy:     envOrDefault("CSP", defaultCSP),
+		CSPReportOnly:             reportOnly,
+		CSPReportURI:              os.Getenv("CSP_REPORT_URI"),
+		FrameOptions:              envOrDefault("FRAME_OPTIONS", "SAMEORIGIN"),
+		ReferrerPolicy:            envOrDefault("REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		PermissionsPolicy:         envOrDefault("PERMISSIONS_POLICY", "camera=(), microphone=(), geolocation=()"),
+		ContentTypeNosniff:        true,
+		CrossOriginOpenerPolicy:   envOrDefault("CROSS_ORIGIN_OPENER_POLICY", "same-origin"),
+		CrossOriginEmbedderPolicy: os.Getenv("CROSS_ORIGIN_EMBEDDER_POLICY"),
+	}
+}
+
+func envOrDefault(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+// SecurityHeaders sets the headers configured in r.Security. When the content security policy
+// uses a nonce, the nonce is added to the request context, where templ adds it to the script
+// elements it generates, and render makes it available as ctx.Value("nonce") and TemplateData.Nonce
+func (r *Rapidus) SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cfg := r.Security
+		h := w.Header()
+
+		if cfg.ContentSecurityPolicy != "" {
+			policy := cfg.ContentSecurityPolicy
+			if strings.Contains(policy, "{nonce}") {
+				nonce, err := generateNonce()
+				if err != nil {
+					r.ErrorLog.Println(err)
+					r.Error500For(w, req)
+					return
+				}
+				policy = strings.ReplaceAll(policy, "{nonce}", nonce)
+				req = req.WithContext(templ.WithNonce(req.Context(), nonce))
+			}
+
+			if cfg.CSPReportURI != "" && !strings.Contains(policy, "report-uri") {
+				policy += "; report-uri " + cfg.CSPReportURI
+			}
+
+			if cfg.CSPReportOnly {
+				h.Set("Content-Security-Policy-Report-Only", policy)
+			} else {
+				h.Set("Content-Security-Policy", policy)
+			}
+		}
+
+		setHeaderIfNotEmpty(h, "X-Frame-Options", cfg.FrameOptions)
+		setHeaderIfNotEmpty(h, "Referrer-Policy", cfg.ReferrerPolicy)
+		setHeaderIfNotEmpty(h, "Permissions-Policy", cfg.PermissionsPolicy)
+		setHeaderIfNotEmpty(h, "Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy)
+		setHeaderIfNotEmpty(h, "Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy)
+		if cfg.ContentTypeNosniff {
+			h.Set("X-Content-Type-Options", "nosniff")
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// maxCSPReport is how much of a content security policy report is logged
+const maxCSPReport = 2048
+
+// CSPReportHandler logs the content security policy violations browsers report to CSPReportURI.
+// Anyone can post to it, so reports are cut to maxCSPReport bytes, and routes rate limits it per client
+func (r *Rapidus) CSPReportHandler(w http.ResponseWriter, req *http.Request) {
+	report, err := io.ReadAll(io.LimitReader(req.Body, maxCSPReport+1))
+	if err != nil {
+		r.ErrorStatusFor(w, req, http.StatusBadRequest)
+		return
+	}
+
+	if len(report) > maxCSPReport {
+		report = append(report[:maxCSPReport], "..."...)
+	}
+	r.ErrorLog.Printf("CSP violation: %q", report)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setHeaderIfNotEmpty(h http.Header, key, value string) {
+	if value != "" {
+		h.Set(key, value)
+	}
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,37 @@
+package rapidus
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecurityConfigFromEnv_ReportOnly(t *testing.T) {
+	t.Setenv("CSP_REPORT_ONLY", "")
+	if !securityConfigFromEnv().CSPReportOnly {
+		t.Error("content security policy enforced without CSP_REPORT_ONLY=false")
+	}
+
+	t.Setenv("CSP_REPORT_ONLY", "false")
+	if securityConfigFromEnv().CSPReportOnly {
+		t.Error("content security policy not enforced with CSP_REPORT_ONLY=false")
+	}
+}
+
+func TestRapidus_CSPReportHandler(t *testing.T) {
+	var out bytes.Buffer
+	app := newTestApp()
+	app.ErrorLog = log.New(&out, "", 0)
+
+	w := httptest.NewRecorder()
+	app.CSPReportHandler(w, httptest.NewRequest("POST", "/csp", strings.NewReader(strings.Repeat("x", 64*1024))))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if out.Len() > maxCSPReport+100 {
+		t.Errorf("expected the report to be cut, logged %d bytes", out.Len())
+	}
+}
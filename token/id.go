@@ -0,0 +1,77 @@
+package token
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// UUIDv4 returns a random UUID, as defined in RFC 9562
+func UUIDv4() string {
+	var u [16]byte
+	copy(u[:], Bytes(16))
+
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+
+	return formatUUID(u)
+}
+
+// UUIDv7 returns a UUID that starts with the current Unix time in milliseconds, as defined in
+// RFC 9562. UUIDv7s sort by the time they were created, which makes them good database keys
+func UUIDv7() string {
+	var u [16]byte
+	copy(u[6:], Bytes(10))
+
+	ms := uint64(time.Now().UnixMilli())
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(u[2:], uint32(ms))
+
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+
+	return formatUUID(u)
+}
+
+func formatUUID(u [16]byte) string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+
+	return string(b[:])
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a Universally Unique Lexicographically Sortable Identifier: 26 characters holding
+// the current Unix time in milliseconds followed by 80 random bits
+func ULID() string {
+	var u [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	binary.BigEndian.PutUint32(u[2:], uint32(ms))
+	copy(u[6:], Bytes(10))
+
+	// 128 bits are encoded as 26 characters of 5 bits, the first holding only 3 bits
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+
+	var b [26]byte
+	for i := 25; i >= 0; i-- {
+		b[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(b[:])
+}
@@ -0,0 +1,70 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"math/bits"
+)
+
+// Alphabets for String
+const (
+	Numeric      = "0123456789"
+	Lowercase    = "abcdefghijklmnopqrstuvwxyz"
+	Uppercase    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Hex          = "0123456789abcdef"
+	Alphanumeric = Lowercase + Uppercase + Numeric
+	// AlphanumericSymbols is the alphabet of Rapidus.RandomString, and is safe to use in .env files
+	AlphanumericSymbols = Alphanumeric + "_+"
+	// Readable leaves out characters that are easily confused, such as 0 and O, or 1 and l
+	Readable = "23456789abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// Bytes returns n random bytes
+func Bytes(n int) []byte {
+	b := make([]byte, n)
+	// crypto/rand.Read never returns an error, it crashes the program if randomness is unavailable
+	_, _ = rand.Read(b)
+	return b
+}
+
+// String returns a random string of n characters from alphabet, which must hold between 2 and 256
+// unique bytes. Every character is equally likely: random bytes are masked to the smallest power
+// of two that covers the alphabet, and rejected when they fall outside it, instead of taking them
+// modulo the alphabet length
+func String(n int, alphabet string) string {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		panic("token: alphabet must hold between 2 and 256 characters")
+	}
+
+	mask := byte(1<<bits.Len(uint(len(alphabet)-1)) - 1)
+
+	// read enough bytes for most strings in one go, allowing for rejected bytes
+	size := n + n/2 + 8
+	result := make([]byte, 0, n)
+	for len(result) < n {
+		for _, b := range Bytes(size) {
+			if i := int(b & mask); i < len(alphabet) {
+				result = append(result, alphabet[i])
+				if len(result) == n {
+					break
+				}
+			}
+		}
+	}
+
+	return string(result)
+}
+
+// Base64 returns n random bytes as URL safe base64, without padding
+func Base64(n int) string {
+	return base64.RawURLEncoding.EncodeToString(Bytes(n))
+}
+
+// base32Encoding is lowercase, so tokens can be used where case is not preserved, e.g. host names
+var base32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Base32 returns n random bytes as lowercase base32, without padding
+func Base32(n int) string {
+	return base32Encoding.EncodeToString(Bytes(n))
+}
@@ -0,0 +1,165 @@
+package token
+
+import (
+	"encoding/base64"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	for _, alphabet := range []string{Numeric, Hex, Alphanumeric, AlphanumericSymbols, Readable, "ab"} {
+		for _, n := range []int{0, 1, 32, 1000} {
+			s := String(n, alphabet)
+			if len(s) != n {
+				t.Errorf("expected %d characters but got %d", n, len(s))
+			}
+
+			for _, c := range s {
+				if !strings.ContainsRune(alphabet, c) {
+					t.Errorf("%q is not in alphabet %s", c, alphabet)
+				}
+			}
+		}
+	}
+}
+
+func TestString_InvalidAlphabet(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an alphabet of one character")
+		}
+	}()
+
+	String(10, "a")
+}
+
+// TestString_Distribution checks with a chi-squared test that every character is equally likely.
+// Alphabets that are not a power of two in length are the ones a modulo would bias
+func TestString_Distribution(t *testing.T) {
+	for _, alphabet := range []string{Numeric, Alphanumeric, AlphanumericSymbols, Readable} {
+		const n = 200000
+		counts := make(map[rune]int)
+		for _, c := range String(n, alphabet) {
+			counts[c]++
+		}
+
+		if len(counts) != len(alphabet) {
+			t.Errorf("expected all %d characters of %s, got %d", len(alphabet), alphabet, len(counts))
+		}
+
+		expected := float64(n) / float64(len(alphabet))
+		chi := 0.0
+		for _, count := range counts {
+			chi += math.Pow(float64(count)-expected, 2) / expected
+		}
+
+		// the 99.9th percentile of chi-squared is below df + 5*sqrt(2*df) for these alphabet sizes
+		df := float64(len(alphabet) - 1)
+		if limit := df + 5*math.Sqrt(2*df); chi > limit {
+			t.Errorf("characters of %s are not uniformly distributed: chi-squared %.1f > %.1f", alphabet, chi, limit)
+		}
+	}
+}
+
+func TestBase64AndBase32(t *testing.T) {
+	b64 := Base64(32)
+	decoded, err := base64.RawURLEncoding.DecodeString(b64)
+	if err != nil || len(decoded) != 32 {
+		t.Errorf("invalid base64 token %s", b64)
+	}
+
+	if !regexp.MustCompile(`^[a-z2-7]{52}$`).MatchString(Base32(32)) {
+		t.Error("invalid base32 token")
+	}
+
+	if Base64(16) == Base64(16) {
+		t.Error("two tokens are the same")
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestUUID(t *testing.T) {
+	for version, generate := range map[string]func() string{"4": UUIDv4, "7": UUIDv7} {
+		id := generate()
+		match := uuidPattern.FindStringSubmatch(id)
+		if match == nil || match[1] != version {
+			t.Errorf("invalid UUIDv%s %s", version, id)
+		}
+	}
+}
+
+func TestUUIDv7_Sortable(t *testing.T) {
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, UUIDv7())
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	if !sort.StringsAreSorted(ids) {
+		t.Error("UUIDv7s created later should sort later:", ids)
+	}
+}
+
+func TestULID(t *testing.T) {
+	before := ULID()
+	time.Sleep(2 * time.Millisecond)
+	id := ULID()
+
+	if !regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`).MatchString(id) {
+		t.Error("invalid ULID", id)
+	}
+
+	if before >= id {
+		t.Error("ULIDs created later should sort later:", before, id)
+	}
+
+	// the first 10 characters hold the time in milliseconds
+	ms := int64(0)
+	for _, c := range id[:10] {
+		ms = ms<<5 | int64(strings.IndexRune(crockford, c))
+	}
+	if diff := time.Since(time.UnixMilli(ms)); diff < 0 || diff > time.Second {
+		t.Error("ULID does not hold the current time:", time.UnixMilli(ms))
+	}
+}
+
+func BenchmarkString32(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		String(32, AlphanumericSymbols)
+	}
+}
+
+func BenchmarkString32Readable(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		String(32, Readable)
+	}
+}
+
+func BenchmarkBase64(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Base64(32)
+	}
+}
+
+func BenchmarkUUIDv4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		UUIDv4()
+	}
+}
+
+func BenchmarkUUIDv7(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		UUIDv7()
+	}
+}
+
+func BenchmarkULID(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ULID()
+	}
+}
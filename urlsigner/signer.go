@@ -7,10 +7,15 @@ import (
 	"time"
 )
 
+// Signer signs a URL with the time it was signed at.
+//
+// Deprecated: the verifier chooses the expiry and the signature is valid for any purpose.
+// Use URLSigner, which embeds the expiry and purpose in the signed URL
 type Signer struct {
 	Secret []byte
 }
 
+// GenerateTokenFromString signs a URL
 func (s *Signer) GenerateTokenFromString(data string) string {
 	var urlToSign string
 
@@ -27,6 +32,8 @@ func (s *Signer) GenerateTokenFromString(data string) string {
 	return token
 }
 
+// VerifyToken reports whether the token was signed with the secret. It does not check the
+// expiry, which must be done with Expired
 func (s *Signer) VerifyToken(token string) bool {
 	crypt := goalone.New(s.Secret, goalone.Timestamp)
 	_, err := crypt.Unsign([]byte(token))
@@ -37,6 +44,7 @@ func (s *Signer) VerifyToken(token string) bool {
 	return true
 }
 
+// Expired reports whether the token was signed more than minutesUntilExpire minutes ago
 func (s *Signer) Expired(token string, minutesUntilExpire int) bool {
 	crypt := goalone.New(s.Secret, goalone.Timestamp)
 	ts := crypt.Parse([]byte(token))
@@ -0,0 +1,194 @@
+package urlsigner
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/fouched/rapidus/cache"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("urlsigner: invalid signature")
+	ErrExpired          = errors.New("urlsigner: link has expired")
+	ErrWrongPurpose     = errors.New("urlsigner: link was signed for another purpose")
+	ErrAlreadyUsed      = errors.New("urlsigner: link has already been used")
+	ErrNoCache          = errors.New("urlsigner: one-time links need a cache that can add entries atomically")
+)
+
+// Options describe a signed URL
+type Options struct {
+	// TTL is how long the URL is valid for, and is required
+	TTL time.Duration
+	// Purpose binds the URL to what it is for, e.g. password-reset. It must match when verifying
+	Purpose string
+	// OneTime URLs can only be verified once, which is tracked in the cache
+	OneTime bool
+}
+
+// URLSigner signs URLs with an expiry, purpose and optional one-time nonce embedded in the query
+// string, so the verifier doesn't choose how long a link stays valid. URLs are signed with the
+// first secret and verified with any of them, so a key can be rotated by putting the new secret
+// first and keeping the previous ones until the links they signed have expired
+type URLSigner struct {
+	Secrets [][]byte
+	// Cache records used one-time URLs. It must implement cache.Adder, so that a URL verified by two
+	// requests at the same time is only accepted once
+	Cache cache.Cache
+	// Prefix is prepended to the cache keys of used one-time URLs
+	Prefix string
+}
+
+// New creates a URLSigner that signs with secret and also verifies with the previous secrets
+func New(secret []byte, previous ...[]byte) *URLSigner {
+	return &URLSigner{
+		Secrets: append([][]byte{secret}, previous...),
+		Prefix:  "urlsigner:used:",
+	}
+}
+
+// Sign adds an expiry, purpose and, for one-time URLs, a nonce to the query string of rawURL,
+// followed by a signature over the whole URL
+func (s *URLSigner) Sign(rawURL string, opts Options) (string, error) {
+	if len(s.Secrets) == 0 || len(s.Secrets[0]) == 0 {
+		return "", errors.New("urlsigner: no secret to sign with")
+	}
+
+	if opts.TTL <= 0 {
+		return "", errors.New("urlsigner: a signed URL needs a positive TTL")
+	}
+
+	params := url.Values{}
+	params.Set("expires", strconv.FormatInt(time.Now().Add(opts.TTL).Unix(), 10))
+	if opts.Purpose != "" {
+		params.Set("purpose", opts.Purpose)
+	}
+	if opts.OneTime {
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		params.Set("once", base64.RawURLEncoding.EncodeToString(nonce))
+	}
+
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+
+	unsigned := rawURL + separator + params.Encode()
+	return unsigned + "&signature=" + signature(s.Secrets[0], unsigned), nil
+}
+
+// OneTime reports whether the Cache can track one-time URLs
+func (s *URLSigner) OneTime() bool {
+	_, ok := s.Cache.(cache.Adder)
+	return ok
+}
+
+// Verify checks that rawURL was signed with one of the secrets for purpose, has not expired and,
+// for one-time URLs, has not been used before, in which case it is now marked as used
+func (s *URLSigner) Verify(rawURL, purpose string) error {
+	return s.verify(rawURL, purpose, true)
+}
+
+// Check checks rawURL like Verify, but does not mark a one-time URL as used. Pages that show a form
+// use it, and Verify the URL when the form is posted, so that mail scanners that fetch the links in
+// a message don't use them up
+func (s *URLSigner) Check(rawURL, purpose string) error {
+	return s.verify(rawURL, purpose, false)
+}
+
+func (s *URLSigner) verify(rawURL, purpose string, use bool) error {
+	i := strings.LastIndex(rawURL, "&signature=")
+	if i < 0 {
+		return ErrInvalidSignature
+	}
+	unsigned, sig := rawURL[:i], rawURL[i+len("&signature="):]
+
+	if !s.validSignature(unsigned, sig) {
+		return ErrInvalidSignature
+	}
+
+	u, err := url.Parse(unsigned)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	query := u.Query()
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	remaining := time.Until(time.Unix(expires, 0))
+	if remaining <= 0 {
+		return ErrExpired
+	}
+
+	if query.Get("purpose") != purpose {
+		return ErrWrongPurpose
+	}
+
+	if !query.Has("once") {
+		return nil
+	}
+
+	if !use {
+		return s.unused(sig)
+	}
+	return s.use(sig, remaining)
+}
+
+func (s *URLSigner) validSignature(unsigned, sig string) bool {
+	for _, secret := range s.Secrets {
+		if len(secret) > 0 && hmac.Equal([]byte(signature(secret, unsigned)), []byte(sig)) {
+			return true
+		}
+	}
+	return false
+}
+
+// use marks a one-time URL as used, until it would have expired anyway
+func (s *URLSigner) use(sig string, remaining time.Duration) error {
+	adder, ok := s.Cache.(cache.Adder)
+	if !ok {
+		return ErrNoCache
+	}
+
+	added, err := adder.Add(s.Prefix+sig, true, int(remaining/time.Second)+1)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return ErrAlreadyUsed
+	}
+	return nil
+}
+
+// unused checks that a one-time URL has not been used
+func (s *URLSigner) unused(sig string) error {
+	if !s.OneTime() {
+		return ErrNoCache
+	}
+
+	used, err := s.Cache.Has(s.Prefix + sig)
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return err
+	}
+	if used {
+		return ErrAlreadyUsed
+	}
+	return nil
+}
+
+func signature(secret []byte, data string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
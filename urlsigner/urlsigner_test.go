@@ -0,0 +1,165 @@
+package urlsigner
+
+import (
+	"errors"
+	"github.com/fouched/rapidus/cache"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryCache is the part of a cache.Cache one-time links use
+type memoryCache map[string]interface{}
+
+func (m memoryCache) Has(key string) (bool, error) {
+	if _, ok := m[key]; !ok {
+		return false, cache.ErrNotFound
+	}
+	return true, nil
+}
+
+func (m memoryCache) Get(key string) (interface{}, error) {
+	v, ok := m[key]
+	if !ok {
+		return nil, cache.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m memoryCache) Set(key string, value interface{}, _ ...int) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryCache) Add(key string, value interface{}, _ ...int) (bool, error) {
+	if _, ok := m[key]; ok {
+		return false, nil
+	}
+	m[key] = value
+	return true, nil
+}
+
+func (m memoryCache) Forget(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memoryCache) EmptyByMatch(string) error { return nil }
+
+func (m memoryCache) Empty() error { return nil }
+
+func TestURLSigner_Verify(t *testing.T) {
+	s := New([]byte("current-secret"))
+
+	signed, err := s.Sign("https://example.com/users/reset?email=me%40here.com", Options{TTL: time.Hour, Purpose: "password-reset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name    string
+		url     string
+		purpose string
+		err     error
+	}{
+		{"valid", signed, "password-reset", nil},
+		{"wrong purpose", signed, "verify-email", ErrWrongPurpose},
+		{"tampered", strings.Replace(signed, "me%40", "you%40", 1), "password-reset", ErrInvalidSignature},
+		{"longer expiry", strings.Replace(signed, "expires=1", "expires=2", 1), "password-reset", ErrInvalidSignature},
+		{"unsigned", "https://example.com/users/reset?email=me%40here.com", "password-reset", ErrInvalidSignature},
+	}
+
+	for _, e := range tests {
+		if err := s.Verify(e.url, e.purpose); err != e.err {
+			t.Errorf("%s: expected %v but got %v", e.name, e.err, err)
+		}
+	}
+}
+
+func TestURLSigner_Expired(t *testing.T) {
+	s := New([]byte("current-secret"))
+
+	signed, _ := s.Sign("https://example.com/download", Options{TTL: time.Second})
+	time.Sleep(1100 * time.Millisecond)
+
+	if err := s.Verify(signed, ""); err != ErrExpired {
+		t.Error("expected ErrExpired, got", err)
+	}
+
+	if _, err := s.Sign("https://example.com/download", Options{}); err == nil {
+		t.Error("no error signing without a TTL")
+	}
+}
+
+func TestURLSigner_Rotation(t *testing.T) {
+	old := New([]byte("old-secret"))
+	signed, _ := old.Sign("https://example.com/download", Options{TTL: time.Hour})
+
+	rotated := New([]byte("new-secret"), []byte("old-secret"))
+	if err := rotated.Verify(signed, ""); err != nil {
+		t.Error("URL signed with a previous secret should verify:", err)
+	}
+
+	retired := New([]byte("new-secret"))
+	if err := retired.Verify(signed, ""); err != ErrInvalidSignature {
+		t.Error("URL signed with a retired secret should not verify:", err)
+	}
+}
+
+func TestURLSigner_OneTime(t *testing.T) {
+	s := New([]byte("current-secret"))
+	signed, _ := s.Sign("https://example.com/users/reset", Options{TTL: time.Hour, OneTime: true})
+
+	if err := s.Verify(signed, ""); err != ErrNoCache {
+		t.Error("expected ErrNoCache, got", err)
+	}
+
+	s.Cache = memoryCache{}
+	if err := s.Check(signed, ""); err != nil {
+		t.Error("unused link should check:", err)
+	}
+
+	if err := s.Verify(signed, ""); err != nil {
+		t.Error("first use should verify:", err)
+	}
+
+	if err := s.Check(signed, ""); err != ErrAlreadyUsed {
+		t.Error("expected ErrAlreadyUsed checking a used link, got", err)
+	}
+
+	if err := s.Verify(signed, ""); err != ErrAlreadyUsed {
+		t.Error("expected ErrAlreadyUsed, got", err)
+	}
+}
+
+// downCache is a cache that can't be reached
+type downCache struct {
+	memoryCache
+}
+
+func (downCache) Has(string) (bool, error) { return false, errUnavailable }
+
+var errUnavailable = errors.New("connection refused")
+
+func TestURLSigner_OneTime_cacheError(t *testing.T) {
+	s := New([]byte("current-secret"))
+	s.Cache = downCache{memoryCache{}}
+	signed, _ := s.Sign("https://example.com/users/reset", Options{TTL: time.Hour, OneTime: true})
+
+	if err := s.Check(signed, ""); !errors.Is(err, errUnavailable) {
+		t.Error("expected the cache error, got", err)
+	}
+}
+
+func TestSigner_Compatibility(t *testing.T) {
+	s := Signer{Secret: []byte("current-secret")}
+
+	token := s.GenerateTokenFromString("https://example.com/users/reset?email=me%40here.com")
+	if !s.VerifyToken(token) {
+		t.Error("token should verify")
+	}
+
+	if s.Expired(token, 60) {
+		t.Error("token should not have expired")
+	}
+}